@@ -0,0 +1,315 @@
+// File: todoApi/backend/cmd/api/config.go
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/mail"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// version is the build version, it can be set at build time with -ldflags "-X main.version=1.2.3"
+var version = "dev"
+
+// configuration struct to hold configuration settings
+type config struct {
+	port            int           //port on which the databased will open on
+	env             string        //which environment we are working in: development, staging or production
+	shutdownTimeout time.Duration //how long in-flight requests and background tasks get to finish on shutdown
+	storage         string        //where the data is kept: "postgres", "sqlite" or "memory", which is lost on restart
+	db              struct {      //database limiters and dependencies
+		dsn          string        //connection to databases
+		maxOpenConns int           //limit of open connections
+		maxIdleConns int           //limit of idle connections
+		maxIdleTime  time.Duration //limit on idle time
+		autoMigrate  bool          //apply pending migrations on startup
+		queryTimeout time.Duration //how long a single query may run before it is cancelled
+	}
+	sqlite struct { //single file database for self-hosted installs
+		path string
+	}
+	log struct { //structured logging settings
+		level  slog.Level //minimum level that is written: debug, info, warn or error
+		format string     //"json" or "text"
+	}
+	limiter struct { //token bucket rate limiting per client IP address
+		enabled        bool
+		rps            float64 //requests per second a client is allowed on average
+		burst          int     //requests a client may make at once
+		trustedProxies string  //comma separated IPs or CIDRs whose X-Forwarded-For and X-Real-IP headers are believed
+	}
+	cors struct {
+		trustedOrigins string //comma separated origins, e.g. http://localhost:8000, that browsers may call the API from
+	}
+	cursor struct {
+		secret string //signs the pagination cursors, a random one is made on startup when empty
+	}
+	subtasks struct {
+		completion string //what completing a parent does to its subtasks: "block", "cascade" or "ignore"
+	}
+	smtp struct { //mail server used for activation and password reset emails, mail is logged when no host is set
+		host     string
+		port     int
+		username string
+		password string
+		sender   string
+	}
+	cli struct { //command-line only settings that are never read from the environment or a file
+		configFile  string
+		version     bool
+		printConfig bool
+	}
+}
+
+// defaultConfig() returns the settings used when nothing else is provided
+func defaultConfig() config {
+	var cfg config
+	cfg.port = 4000
+	cfg.env = "development"
+	cfg.shutdownTimeout = 20 * time.Second
+	cfg.storage = "postgres"
+	cfg.db.maxOpenConns = 25
+	cfg.db.maxIdleConns = 25
+	cfg.db.maxIdleTime = 15 * time.Minute
+	cfg.db.queryTimeout = 3 * time.Second
+	cfg.sqlite.path = "todo.db"
+	cfg.log.level = slog.LevelInfo
+	cfg.log.format = "json"
+	cfg.limiter.enabled = true
+	cfg.limiter.rps = 2
+	cfg.limiter.burst = 4
+	cfg.subtasks.completion = "block"
+	cfg.smtp.port = 587
+	cfg.smtp.sender = "Todo <no-reply@todo.michaelgomez.net>"
+	return cfg
+}
+
+// secretSettings are redacted by -print-config
+var secretSettings = map[string]bool{
+	"db-dsn":        true,
+	"cursor-secret": true,
+	"smtp-password": true,
+}
+
+// cliOnlySettings cannot be set from the environment or a config file
+var cliOnlySettings = map[string]bool{
+	"config":       true,
+	"version":      true,
+	"print-config": true,
+}
+
+// The flagSet() function binds every setting to a flag, the current values of cfg are used as the defaults
+func flagSet(cfg *config) *flag.FlagSet {
+	fs := flag.NewFlagSet("api", flag.ContinueOnError)
+
+	fs.IntVar(&cfg.port, "port", cfg.port, "API server port")
+	fs.StringVar(&cfg.env, "env", cfg.env, "Environment (development|staging|production)")
+	fs.DurationVar(&cfg.shutdownTimeout, "shutdown-timeout", cfg.shutdownTimeout, "Time allowed for graceful shutdown (e.g. 20s)")
+
+	fs.StringVar(&cfg.storage, "storage", cfg.storage, "Storage backend (postgres|sqlite|memory)")
+	fs.StringVar(&cfg.db.dsn, "db-dsn", cfg.db.dsn, "PostgreSQL DSN")
+	fs.IntVar(&cfg.db.maxOpenConns, "db-max-open-conns", cfg.db.maxOpenConns, "PostgreSQL max open connections")
+	fs.IntVar(&cfg.db.maxIdleConns, "db-max-idle-conns", cfg.db.maxIdleConns, "PostgreSQL max idle connections")
+	fs.DurationVar(&cfg.db.maxIdleTime, "db-max-idle-time", cfg.db.maxIdleTime, "PostgreSQL max connection idle time (e.g. 15m)")
+	fs.DurationVar(&cfg.db.queryTimeout, "db-query-timeout", cfg.db.queryTimeout, "Longest a single database query may run (e.g. 3s)")
+	fs.BoolVar(&cfg.db.autoMigrate, "auto-migrate", cfg.db.autoMigrate, "Apply pending database migrations on startup, always done for sqlite")
+	fs.StringVar(&cfg.sqlite.path, "sqlite-path", cfg.sqlite.path, "SQLite database file")
+
+	fs.TextVar(&cfg.log.level, "log-level", cfg.log.level, "Minimum log level (debug|info|warn|error)")
+	fs.StringVar(&cfg.log.format, "log-format", cfg.log.format, "Log format (json|text)")
+
+	fs.BoolVar(&cfg.limiter.enabled, "limiter-enabled", cfg.limiter.enabled, "Enable rate limiter")
+	fs.Float64Var(&cfg.limiter.rps, "limiter-rps", cfg.limiter.rps, "Rate limiter maximum requests per second")
+	fs.IntVar(&cfg.limiter.burst, "limiter-burst", cfg.limiter.burst, "Rate limiter maximum burst")
+	fs.StringVar(&cfg.limiter.trustedProxies, "limiter-trusted-proxies", cfg.limiter.trustedProxies, "Comma separated proxy IPs or CIDRs whose forwarding headers are trusted")
+
+	fs.StringVar(&cfg.cors.trustedOrigins, "cors-trusted-origins", cfg.cors.trustedOrigins, "Comma separated trusted CORS origins")
+
+	fs.StringVar(&cfg.cursor.secret, "cursor-secret", cfg.cursor.secret, "Secret that signs pagination cursors, at least 32 characters, random per process when empty")
+
+	fs.StringVar(&cfg.subtasks.completion, "subtasks-completion", cfg.subtasks.completion, "Completing a task with open subtasks (block|cascade|ignore)")
+
+	fs.StringVar(&cfg.smtp.host, "smtp-host", cfg.smtp.host, "SMTP host, emails are logged when empty")
+	fs.IntVar(&cfg.smtp.port, "smtp-port", cfg.smtp.port, "SMTP port")
+	fs.StringVar(&cfg.smtp.username, "smtp-username", cfg.smtp.username, "SMTP username")
+	fs.StringVar(&cfg.smtp.password, "smtp-password", cfg.smtp.password, "SMTP password")
+	fs.StringVar(&cfg.smtp.sender, "smtp-sender", cfg.smtp.sender, "SMTP sender")
+
+	fs.StringVar(&cfg.cli.configFile, "config", cfg.cli.configFile, "Path to a JSON config file")
+	fs.BoolVar(&cfg.cli.version, "version", cfg.cli.version, "Display version and exit")
+	fs.BoolVar(&cfg.cli.printConfig, "print-config", cfg.cli.printConfig, "Display the effective configuration and exit")
+
+	return fs
+}
+
+// envName() returns the environment variable for a setting, e.g. db-dsn is read from TODO_DB_DSN
+func envName(setting string) string {
+	return "TODO_" + strings.ToUpper(strings.ReplaceAll(setting, "-", "_"))
+}
+
+// The loadConfig() function builds the configuration from, in increasing order of precedence,
+// the defaults, the JSON config file, TODO_* environment variables and command-line flags
+// the result still has to be checked with validateConfig()
+func loadConfig(args []string, getenv func(string) string) (config, error) {
+	cfg := defaultConfig()
+	fs := flagSet(&cfg)
+	fs.SetOutput(io.Discard)
+
+	err := fs.Parse(args)
+	if err != nil {
+		//the usage is only printed for -help, other parse errors are reported by the caller
+		if errors.Is(err, flag.ErrHelp) {
+			fs.SetOutput(os.Stderr)
+			fs.PrintDefaults()
+		}
+		return cfg, err
+	}
+
+	//remembering what was set on the command line so nothing else overrides it
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	if cfg.cli.configFile == "" {
+		cfg.cli.configFile = getenv(envName("config"))
+	}
+	if cfg.cli.configFile != "" {
+		err = applyConfigFile(fs, cfg.cli.configFile, explicit)
+		if err != nil {
+			return cfg, err
+		}
+	}
+
+	//environment variables override the config file
+	var envErr error
+	fs.VisitAll(func(f *flag.Flag) {
+		name := envName(f.Name)
+		value := getenv(name)
+		if envErr != nil || value == "" || explicit[f.Name] || cliOnlySettings[f.Name] {
+			return
+		}
+		if err := fs.Set(f.Name, value); err != nil {
+			envErr = fmt.Errorf("invalid value %q for %s: %v", value, name, err)
+		}
+	})
+	return cfg, envErr
+}
+
+// applyConfigFile() sets every setting found in a JSON object keyed by flag name, skipping the explicit ones
+func applyConfigFile(fs *flag.FlagSet, path string, explicit map[string]bool) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+
+	var settings map[string]interface{}
+	err = json.Unmarshal(content, &settings)
+	if err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	for name, raw := range settings {
+		if fs.Lookup(name) == nil || cliOnlySettings[name] {
+			return fmt.Errorf("config file %s: unknown setting %q", path, name)
+		}
+		if explicit[name] {
+			continue
+		}
+
+		//numbers and booleans are accepted as well as strings
+		var value string
+		switch raw := raw.(type) {
+		case string:
+			value = raw
+		case float64, bool:
+			value = fmt.Sprint(raw)
+		default:
+			return fmt.Errorf("config file %s: setting %q must be a string, number or boolean", path, name)
+		}
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("config file %s: invalid value %q for %q: %v", path, value, name, err)
+		}
+	}
+	return nil
+}
+
+// The validateConfig() function checks every setting and reports all of the problems at once
+func validateConfig(cfg config) error {
+	var problems []string
+	check := func(ok bool, setting, message string) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s (%s): %s", setting, envName(setting), message))
+		}
+	}
+
+	check(cfg.port > 0 && cfg.port <= 65535, "port", "must be between 1 and 65535")
+	check(cfg.shutdownTimeout > 0, "shutdown-timeout", "must be a positive duration such as 20s")
+	check(cfg.env == "development" || cfg.env == "staging" || cfg.env == "production", "env", "must be development, staging or production")
+
+	check(cfg.storage == "postgres" || cfg.storage == "sqlite" || cfg.storage == "memory", "storage", "must be postgres, sqlite or memory")
+	check(cfg.db.dsn != "" || cfg.storage != "postgres", "db-dsn", "must be provided")
+	check(!cfg.db.autoMigrate || cfg.storage != "memory", "auto-migrate", "cannot be used with memory storage")
+	check(cfg.sqlite.path != "" || cfg.storage != "sqlite", "sqlite-path", "must be provided")
+	check(cfg.db.maxOpenConns > 0, "db-max-open-conns", "must be greater than zero")
+	check(cfg.db.maxIdleConns >= 0, "db-max-idle-conns", "must not be negative")
+	check(cfg.db.maxIdleConns <= cfg.db.maxOpenConns, "db-max-idle-conns", "must not be more than db-max-open-conns")
+	check(cfg.db.maxIdleTime > 0, "db-max-idle-time", "must be a positive duration such as 15m")
+	check(cfg.db.queryTimeout > 0, "db-query-timeout", "must be a positive duration such as 3s")
+
+	check(cfg.log.format == "json" || cfg.log.format == "text", "log-format", "must be json or text")
+
+	if cfg.limiter.enabled {
+		check(cfg.limiter.rps > 0, "limiter-rps", "must be greater than zero")
+		check(cfg.limiter.burst > 0, "limiter-burst", "must be greater than zero")
+	}
+	_, err := parseTrustedProxies(cfg.limiter.trustedProxies)
+	check(err == nil, "limiter-trusted-proxies", "must be a comma separated list of IP addresses or CIDR ranges")
+
+	for _, origin := range splitList(cfg.cors.trustedOrigins) {
+		u, err := url.Parse(origin)
+		ok := err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" && u.Path == "" && u.RawQuery == ""
+		check(ok, "cors-trusted-origins", fmt.Sprintf("%q must be a scheme and host such as https://example.com", origin))
+	}
+
+	check(cfg.cursor.secret == "" || len(cfg.cursor.secret) >= 32, "cursor-secret", "must be at least 32 characters")
+
+	check(cfg.subtasks.completion == "block" || cfg.subtasks.completion == "cascade" || cfg.subtasks.completion == "ignore", "subtasks-completion", "must be block, cascade or ignore")
+
+	check(cfg.smtp.port > 0 && cfg.smtp.port <= 65535, "smtp-port", "must be between 1 and 65535")
+	_, err = mail.ParseAddress(cfg.smtp.sender)
+	check(err == nil, "smtp-sender", "must be a valid email address such as Todo <no-reply@example.com>")
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// The printConfig() function writes the effective configuration as a JSON config file with the secrets redacted
+func printConfig(w io.Writer, cfg config) error {
+	settings := make(map[string]string)
+	flagSet(&cfg).VisitAll(func(f *flag.Flag) {
+		if cliOnlySettings[f.Name] {
+			return
+		}
+		value := f.Value.String()
+		if secretSettings[f.Name] && value != "" {
+			value = "********"
+		}
+		settings[f.Name] = value
+	})
+
+	//maps are encoded with sorted keys so the output is stable
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "\t")
+	return enc.Encode(settings)
+}
@@ -0,0 +1,92 @@
+// File: todoApi/backend/cmd/api/context.go
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"todo.michaelgomez.net/internal/data"
+)
+
+// contextKey is a private type so our keys cannot collide with other packages
+type contextKey string
+
+const (
+	userContextKey        = contextKey("user")
+	permissionsContextKey = contextKey("permissions")
+	requestContextKey     = contextKey("request")
+)
+
+// requestInfo holds the details of a request that are written with every log record about it
+// it is shared by pointer so the user identified further down the chain is visible to logRequest()
+type requestInfo struct {
+	id     string
+	userID int64
+}
+
+// The contextSetUser() method returns a copy of the request with the user added to its context
+func (app *application) contextSetUser(r *http.Request, user *data.User) *http.Request {
+	if info := app.contextGetRequestInfo(r); info != nil {
+		info.userID = user.ID
+	}
+	ctx := context.WithValue(r.Context(), userContextKey, user)
+	return r.WithContext(ctx)
+}
+
+// The contextGetUser() method retrieves the user from the request context
+// requests that have not been identified belong to the anonymous user
+func (app *application) contextGetUser(r *http.Request) *data.User {
+	user, ok := r.Context().Value(userContextKey).(*data.User)
+	if !ok {
+		return data.AnonymousUser
+	}
+	return user
+}
+
+// The contextSetPermissions() method returns a copy of the request with the user's permissions added to its context
+func (app *application) contextSetPermissions(r *http.Request, permissions data.Permissions) *http.Request {
+	ctx := context.WithValue(r.Context(), permissionsContextKey, permissions)
+	return r.WithContext(ctx)
+}
+
+// The contextGetPermissions() method retrieves the permissions loaded by requirePermission()
+func (app *application) contextGetPermissions(r *http.Request) data.Permissions {
+	permissions, ok := r.Context().Value(permissionsContextKey).(data.Permissions)
+	if !ok {
+		return nil
+	}
+	return permissions
+}
+
+// The contextSetRequestInfo() method returns a copy of the request with the request details added to its context
+func (app *application) contextSetRequestInfo(r *http.Request, info *requestInfo) *http.Request {
+	ctx := context.WithValue(r.Context(), requestContextKey, info)
+	return r.WithContext(ctx)
+}
+
+// The contextGetRequestInfo() method retrieves the request details stored by logRequest()
+func (app *application) contextGetRequestInfo(r *http.Request) *requestInfo {
+	info, ok := r.Context().Value(requestContextKey).(*requestInfo)
+	if !ok {
+		return nil
+	}
+	return info
+}
+
+// The requestAttrs() method returns the log attributes describing a request
+func (app *application) requestAttrs(r *http.Request) []any {
+	attrs := []any{"method", r.Method, "url", r.URL.RequestURI()}
+	if info := app.contextGetRequestInfo(r); info != nil {
+		attrs = append(attrs, "request_id", info.id, "user_id", info.userID)
+	}
+	return attrs
+}
+
+// The taskOwner() method returns the userID the task, list and tag queries are scoped to
+// users holding todo:admin can manage everyone's tasks, lists and tags
+func (app *application) taskOwner(r *http.Request) int64 {
+	if app.contextGetPermissions(r).Include(data.PermissionTodoAdmin) {
+		return data.AnyUser
+	}
+	return app.contextGetUser(r).ID
+}
@@ -2,13 +2,64 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
 )
 
-// reports a logged error to the terminal
+// statusClientClosedRequest is the non-standard status nginx logs when the client goes away before the response is sent
+const statusClientClosedRequest = 499
+
+// queryTimeoutRetryAfter is how long clients are asked to wait after a query ran out of time
+const queryTimeoutRetryAfter = 5 * time.Second
+
+// panicError carries a recovered panic value together with the stack of the goroutine that panicked
+type panicError struct {
+	value interface{}
+	stack []byte
+}
+
+func (e panicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.value)
+}
+
+// reports a logged error to the terminal along with the details of the request that caused it
 func (app *application) logError(r *http.Request, err error) {
-	app.logger.Println(err)
+	attrs := app.requestAttrs(r)
+
+	var pe panicError
+	if errors.As(err, &pe) {
+		attrs = append(attrs, "stack", string(pe.stack))
+	}
+	app.logger.Error(err.Error(), attrs...)
+}
+
+// isCanceledQuery() reports whether err comes from a query that was stopped because its context ended
+// Postgres reports a statement cancelled while it was running as query_canceled rather than with the context's error
+func isCanceledQuery(err error) bool {
+	var pqErr *pq.Error
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return true
+	case errors.As(err, &pqErr):
+		return pqErr.Code == "57014"
+	}
+	return false
+}
+
+// setRetryAfter() tells the client how many whole seconds to wait before trying again
+func setRetryAfter(w http.ResponseWriter, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
 }
 
 // to facilitate a json formatted error repsonse
@@ -25,6 +76,16 @@ func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, st
 
 // Server error response
 func (app *application) serverErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	//a query stopped by its context is either the client leaving or the database being too slow, neither is a bug
+	if isCanceledQuery(err) {
+		if r.Context().Err() != nil {
+			app.clientClosedRequestResponse(w, r, err)
+		} else {
+			app.queryTimeoutResponse(w, r, err)
+		}
+		return
+	}
+
 	//We log the error
 	app.logError(r, err)
 
@@ -33,6 +94,24 @@ func (app *application) serverErrorResponse(w http.ResponseWriter, r *http.Reque
 	app.errorResponse(w, r, http.StatusInternalServerError, message)
 }
 
+// The client disconnected, or the server gave up on the request while shutting down, so nobody reads the response
+// only the status is written so the request log and metrics record it
+func (app *application) clientClosedRequestResponse(w http.ResponseWriter, r *http.Request, err error) {
+	attrs := append(app.requestAttrs(r), "status", statusClientClosedRequest, "error", err.Error())
+	app.logger.Warn("request cancelled before it completed", attrs...)
+	w.WriteHeader(statusClientClosedRequest)
+}
+
+// A query ran out of time, the database is overloaded rather than broken so the client is asked to retry
+func (app *application) queryTimeoutResponse(w http.ResponseWriter, r *http.Request, err error) {
+	attrs := append(app.requestAttrs(r), "error", err.Error())
+	app.logger.Warn("database query timed out", attrs...)
+
+	setRetryAfter(w, queryTimeoutRetryAfter)
+	message := "the server is taking too long to respond, please try again later"
+	app.errorResponse(w, r, http.StatusServiceUnavailable, message)
+}
+
 // The not found response
 func (app *application) notFoundReponse(w http.ResponseWriter, r *http.Request) {
 	//Create our message
@@ -63,3 +142,49 @@ func (app *application) editConflictResponse(w http.ResponseWriter, r *http.Requ
 	message := "unable to update the record due to an edit conflict, please try again"
 	app.errorResponse(w, r, http.StatusMethodNotAllowed, message)
 }
+
+// Deleting a list that still owns tasks without asking for a cascade
+func (app *application) listNotEmptyResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the list still contains tasks, move them or delete it with on_delete=cascade"
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
+
+// The email and password pair did not match an account
+func (app *application) invalidCredentialsResponse(w http.ResponseWriter, r *http.Request) {
+	message := "invalid authentication credentials"
+	app.errorResponse(w, r, http.StatusUnauthorized, message)
+}
+
+// The bearer token is missing, malformed, expired or unknown
+func (app *application) invalidAuthenticationTokenResponse(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+
+	message := "invalid or missing authentication token"
+	app.errorResponse(w, r, http.StatusUnauthorized, message)
+}
+
+// An anonymous client tried to use a resource that needs an account
+func (app *application) authenticationRequiredResponse(w http.ResponseWriter, r *http.Request) {
+	message := "you must be authenticated to access this resource"
+	app.errorResponse(w, r, http.StatusUnauthorized, message)
+}
+
+// The user is authenticated but lacks the permission the resource needs
+func (app *application) notPermittedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "your user account doesn't have the necessary permissions to access this resource"
+	app.errorResponse(w, r, http.StatusForbidden, message)
+}
+
+// The user has not activated their account yet
+func (app *application) inactiveAccountResponse(w http.ResponseWriter, r *http.Request) {
+	message := "your user account must be activated to access this resource"
+	app.errorResponse(w, r, http.StatusForbidden, message)
+}
+
+// The client has used up its rate limit, Retry-After tells it how many seconds to wait
+func (app *application) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	setRetryAfter(w, retryAfter)
+
+	message := "rate limit exceeded"
+	app.errorResponse(w, r, http.StatusTooManyRequests, message)
+}
@@ -5,7 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
+	"github.com/julienschmidt/httprouter"
 	"todo.michaelgomez.net/internal/data"
 	"todo.michaelgomez.net/internal/validator"
 )
@@ -13,9 +16,15 @@ import (
 func (app *application) createTaskHandler(w http.ResponseWriter, r *http.Request) {
 	//Our target decode destination
 	var input struct {
-		Title       string `json:"title"`
-		Descritpion string `json:"description"`
-		Completed   bool   `json:"completed"`
+		Title       string   `json:"title"`
+		Descritpion string   `json:"description"`
+		Completed   bool     `json:"completed"`
+		Priority    string   `json:"priority"`
+		DueAt       string   `json:"due_at"`
+		RemindAt    string   `json:"remind_at"`
+		Tags        []string `json:"tags"`
+		ListID      *int64   `json:"list_id"`
+		ParentID    *int64   `json:"parent_id"`
 	}
 
 	//Initialize a new json.Decoder instance
@@ -25,15 +34,36 @@ func (app *application) createTaskHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	//Initialize a new Validator Instance
+	v := validator.New()
+
 	//coping the valeus from the input struct to the new task struct
 	task := &data.Task{
 		Title:       input.Title,
 		Descritpion: input.Descritpion,
 		Completed:   input.Completed,
+		Priority:    data.ParsePriority(input.Priority),
+		Tags:        input.Tags,
+		ListID:      input.ListID,
+		ParentID:    input.ParentID,
+		UserID:      app.contextGetUser(r).ID,
 	}
 
-	//Initialize a new Validator Instance
-	v := validator.New()
+	//a task can only be placed in one of its owner's lists and under a parent that can hold it
+	if !app.checkListExists(w, r, v, task) {
+		return
+	}
+	if !app.checkParent(w, r, v, task) {
+		return
+	}
+
+	//the due date and reminder are optional ISO-8601 timestamps
+	if input.DueAt != "" {
+		task.DueAt = app.parseTime(input.DueAt, "due_at", v)
+	}
+	if input.RemindAt != "" {
+		task.RemindAt = app.parseTime(input.RemindAt, "remind_at", v)
+	}
 
 	//check the map to determine if ther were any validation errors
 	if data.ValidateTask(v, task); !v.Valid() {
@@ -42,10 +72,12 @@ func (app *application) createTaskHandler(w http.ResponseWriter, r *http.Request
 	}
 
 	//Creating a task
-	err = app.models.Tasks.Insert(task)
+	err = app.models.Tasks.Insert(r.Context(), task)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
+		return
 	}
+	app.metrics.tasksCreated.Add(1)
 
 	//Create a location header for the newly created resource/School
 	headers := make(http.Header)
@@ -68,8 +100,20 @@ func (app *application) showTaskHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	//Fetching the specific task
-	task, err := app.models.Tasks.Get(id)
+	//Fetching the specific task, tree=true returns it with all of its subtasks nested inside
+	v := validator.New()
+	tree := app.readBool(r.URL.Query(), "tree", false, v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	var task *data.Task
+	if tree {
+		task, err = app.models.Tasks.GetTree(r.Context(), id, app.taskOwner(r))
+	} else {
+		task, err = app.models.Tasks.Get(r.Context(), id, app.taskOwner(r))
+	}
 
 	//Handling errors
 	if err != nil {
@@ -105,7 +149,7 @@ func (app *application) updateTaskHandler(w http.ResponseWriter, r *http.Request
 	//fmt.Println("debug ! 2")
 
 	//Fetch the original record from the database
-	task, err := app.models.Tasks.Get(id)
+	task, err := app.models.Tasks.Get(r.Context(), id, app.taskOwner(r))
 
 	//fmt.Println("debug ! 3")
 
@@ -125,9 +169,15 @@ func (app *application) updateTaskHandler(w http.ResponseWriter, r *http.Request
 	//Creating an input struct to hold data read in from the client
 	//Updating the input struct to use pointers because pointers have a default value of nil
 	var input struct {
-		Title       *string `json:"title"`
-		Description *string `json:"description"`
-		Completed   *bool   `json:"completed"`
+		Title       *string   `json:"title"`
+		Description *string   `json:"description"`
+		Completed   *bool     `json:"completed"`
+		Priority    *string   `json:"priority"`
+		DueAt       *string   `json:"due_at"`
+		RemindAt    *string   `json:"remind_at"`
+		Tags        *[]string `json:"tags"`
+		ListID      *int64    `json:"list_id"`
+		ParentID    *int64    `json:"parent_id"`
 	}
 
 	//fmt.Println("debug ! 5")
@@ -141,6 +191,12 @@ func (app *application) updateTaskHandler(w http.ResponseWriter, r *http.Request
 
 	//fmt.Println("debug ! 6")
 
+	//Initilize a new Validator Instance
+	v := validator.New()
+
+	//remembering the completion state so we can tell when the task is being completed
+	wasCompleted := task.Completed
+
 	//checking for any updates
 	if input.Title != nil {
 		task.Title = *input.Title
@@ -151,12 +207,49 @@ func (app *application) updateTaskHandler(w http.ResponseWriter, r *http.Request
 	if input.Completed != nil {
 		task.Completed = *input.Completed
 	}
+	if input.Priority != nil {
+		task.Priority = data.ParsePriority(*input.Priority)
+	}
+	if input.Tags != nil {
+		task.Tags = *input.Tags
+	}
+	//moving the task to another list, a list_id of 0 takes it out of its list
+	if input.ListID != nil {
+		task.ListID = nil
+		if *input.ListID != 0 {
+			task.ListID = input.ListID
+			if !app.checkListExists(w, r, v, task) {
+				return
+			}
+		}
+	}
+	//moving the task under another parent, a parent_id of 0 makes it a top level task
+	if input.ParentID != nil {
+		task.ParentID = nil
+		if *input.ParentID != 0 {
+			task.ParentID = input.ParentID
+			if !app.checkParent(w, r, v, task) {
+				return
+			}
+		}
+	}
+	//an empty string clears the due date or reminder
+	if input.DueAt != nil {
+		task.DueAt = nil
+		if *input.DueAt != "" {
+			task.DueAt = app.parseTime(*input.DueAt, "due_at", v)
+		}
+	}
+	if input.RemindAt != nil {
+		task.RemindAt = nil
+		if *input.RemindAt != "" {
+			task.RemindAt = app.parseTime(*input.RemindAt, "remind_at", v)
+		}
+	}
 
 	//fmt.Println("debug ! 7")
 
 	//Performing validation on the updated task. If validation fails, then we send a 422 - unprocessable enitiy response to the client
-	//Initilize a new Validator Instance
-	v := validator.New()
 
 	//fmt.Println("debug ! 8")
 
@@ -169,16 +262,27 @@ func (app *application) updateTaskHandler(w http.ResponseWriter, r *http.Request
 	//fmt.Println("debug ! 9")
 
 	//Passing the updated task record to the update() method
-	err = app.models.Tasks.Update(task)
+	//completing the task is blocked by its incomplete subtasks or completes them too, depending on the configuration
+	err = app.models.Tasks.Update(r.Context(), task, data.SubtaskCompletion(app.config.subtasks.completion))
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrEditConflict):
 			app.editConflictResponse(w, r)
+		case errors.Is(err, data.ErrIncompleteSubtasks):
+			v.AddError("completed", "must not be set while the task has incomplete subtasks")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrParentCycle), errors.Is(err, data.ErrTaskTooDeep):
+			//another move got in between the check above and the update
+			data.AddParentError(v, err)
+			app.failedValidationResponse(w, r, v.Errors)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
 		return
 	}
+	if task.Completed && !wasCompleted {
+		app.metrics.tasksCompleted.Add(1)
+	}
 
 	//fmt.Println("debug ! 10")
 
@@ -201,7 +305,7 @@ func (app *application) deleteTaskHandler(w http.ResponseWriter, r *http.Request
 	}
 
 	//deleting the school from the database, send a 404 not found status code to the client if there is no matching record
-	err = app.models.Tasks.Delete(id)
+	err = app.models.Tasks.Delete(r.Context(), id, app.taskOwner(r))
 
 	//handling errors
 	if err != nil {
@@ -213,6 +317,7 @@ func (app *application) deleteTaskHandler(w http.ResponseWriter, r *http.Request
 		}
 		return
 	}
+	app.metrics.tasksDeleted.Add(1)
 
 	//Returning 200 status ok to the client with a success message
 	err = app.writeJSON(w, http.StatusOK, envelope{"message": "task sucessfully deleted"}, nil)
@@ -223,11 +328,31 @@ func (app *application) deleteTaskHandler(w http.ResponseWriter, r *http.Request
 
 // The listtask handler allows the client to see a listing of a schools based on a set of criteria
 func (app *application) listTasksHandler(w http.ResponseWriter, r *http.Request) {
+	app.listTasks(w, r, nil)
+}
+
+// The listTasks() method writes a filtered listing of tasks, a non-nil listID limits it to the tasks of that list
+// otherwise the list_id query parameter is honoured
+func (app *application) listTasks(w http.ResponseWriter, r *http.Request, listID *int64) {
 	//creating an input struct to hold our query parameters
+	//completed is left nil to list open and completed tasks alike, the not_ parameters exclude what they name
 	var input struct {
-		Title       string
-		Description string
-		Completed   bool
+		ListID        *int64
+		Title         string
+		Description   string
+		Completed     *bool
+		DueBefore     *time.Time
+		DueAfter      *time.Time
+		CreatedBefore *time.Time
+		CreatedAfter  *time.Time
+		Overdue       bool
+		IDs           []int64
+		NotIDs        []int64
+		Priorities    []data.Priority
+		NotPriorities []data.Priority
+		Tags          []string
+		NotTags       []string
+		TagMode       string
 		data.Filters
 	}
 
@@ -238,15 +363,61 @@ func (app *application) listTasksHandler(w http.ResponseWriter, r *http.Request)
 	qs := r.URL.Query()
 
 	//Using the helper method to extract the values
+	input.ListID = listID
+	if input.ListID == nil && qs.Get("list_id") != "" {
+		id := int64(app.readInt(qs, "list_id", 0, v))
+		input.ListID = &id
+	}
 	input.Title = app.readString(qs, "title", "")
 	input.Description = app.readString(qs, "decription", "")
-	input.Completed = app.readBool(qs, "completed", false, v)
+	input.Completed = app.readOptionalBool(qs, "completed", v)
+	input.DueBefore = app.readTime(qs, "due_before", v)
+	input.DueAfter = app.readTime(qs, "due_after", v)
+	input.CreatedBefore = app.readTime(qs, "created_before", v)
+	input.CreatedAfter = app.readTime(qs, "created_after", v)
+	input.Overdue = app.readBool(qs, "overdue", false, v)
+
+	//id and not_id accept a comma separated list of task ids e.g. id=1,2,3
+	input.IDs = app.readIDs(qs, "id", v)
+	input.NotIDs = app.readIDs(qs, "not_id", v)
+
+	//priority and not_priority accept a comma separated list of levels e.g. priority=high,urgent
+	readPriorities := func(key string) []data.Priority {
+		var priorities []data.Priority
+		for _, name := range app.readCSV(qs, key, []string{}) {
+			priority := data.ParsePriority(name)
+			if name == "" || !priority.Valid() {
+				v.AddError(key, "must be a list of none, low, medium, high or urgent")
+				continue
+			}
+			priorities = append(priorities, priority)
+		}
+		return priorities
+	}
+	input.Priorities = readPriorities("priority")
+	input.NotPriorities = readPriorities("not_priority")
+
+	//tag and not_tag accept a comma separated list of tag names, tag_mode decides if a task needs any or all of them
+	input.Tags = app.readCSV(qs, "tag", []string{})
+	input.NotTags = app.readCSV(qs, "not_tag", []string{})
+	for key, names := range map[string][]string{"tag": input.Tags, "not_tag": input.NotTags} {
+		for _, name := range names {
+			data.ValidateTagName(v, key, name)
+		}
+		v.Check(validator.Unique(names), key, "must not contain duplicate values")
+	}
+	input.TagMode = app.readString(qs, "tag_mode", "any")
+	v.Check(validator.In(input.TagMode, "any", "all"), "tag_mode", "must be any or all")
 
 	//filering now
 	input.Filters.Page = app.readInt(qs, "page", 1, v)
 	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
 	input.Filters.Sort = app.readString(qs, "sort", "id")
-	input.Filters.SortList = []string{"id", "title", "completed", "-id", "-description", "-completed"}
+	input.Filters.SortColumns = data.TaskSortColumns
+	//cursor continues from a next_cursor or prev_cursor, include_total=false skips counting every match
+	input.Filters.Cursor = app.readString(qs, "cursor", "")
+	input.Filters.CursorKey = app.cursorKey
+	input.Filters.IncludeTotal = app.readBool(qs, "include_total", true, v)
 
 	//checking for validation errors
 	if data.ValidateFilter(v, input.Filters); !v.Valid() {
@@ -256,8 +427,56 @@ func (app *application) listTasksHandler(w http.ResponseWriter, r *http.Request)
 
 	//fmt.Println("Debug ! 3")
 
+	//turning the parameters that were given into the conditions of the listing
+	var filter data.TaskFilter
+	if input.ListID != nil {
+		filter = append(filter, data.InList(*input.ListID))
+	}
+	if input.Title != "" {
+		filter = append(filter, data.TitleMatches(input.Title))
+	}
+	if input.Description != "" {
+		filter = append(filter, data.DescriptionMatches(input.Description))
+	}
+	if input.Completed != nil {
+		filter = append(filter, data.CompletedIs(*input.Completed))
+	}
+	if input.DueBefore != nil {
+		filter = append(filter, data.DueBefore(*input.DueBefore))
+	}
+	if input.DueAfter != nil {
+		filter = append(filter, data.DueAfter(*input.DueAfter))
+	}
+	if input.CreatedBefore != nil {
+		filter = append(filter, data.CreatedBefore(*input.CreatedBefore))
+	}
+	if input.CreatedAfter != nil {
+		filter = append(filter, data.CreatedAfter(*input.CreatedAfter))
+	}
+	if input.Overdue {
+		filter = append(filter, data.Overdue(time.Now()))
+	}
+	if len(input.IDs) > 0 {
+		filter = append(filter, data.IDIn(input.IDs...))
+	}
+	if len(input.NotIDs) > 0 {
+		filter = append(filter, data.Not(data.IDIn(input.NotIDs...)))
+	}
+	if len(input.Priorities) > 0 {
+		filter = append(filter, data.PriorityIn(input.Priorities...))
+	}
+	if len(input.NotPriorities) > 0 {
+		filter = append(filter, data.Not(data.PriorityIn(input.NotPriorities...)))
+	}
+	if len(input.Tags) > 0 {
+		filter = append(filter, data.TaggedWith(input.Tags, input.TagMode == "all"))
+	}
+	if len(input.NotTags) > 0 {
+		filter = append(filter, data.Not(data.TaggedWith(input.NotTags, false)))
+	}
+
 	//Geting a listing of all tasks
-	tasks, metadata, err := app.models.Tasks.GetAll(input.Title, input.Description, input.Completed, input.Filters)
+	tasks, metadata, err := app.models.Tasks.GetAll(r.Context(), app.taskOwner(r), filter, input.Filters)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
@@ -274,3 +493,159 @@ func (app *application) listTasksHandler(w http.ResponseWriter, r *http.Request)
 
 	//fmt.Println("Debug ! 1")
 }
+
+// The searchTasksHandler() method ranks the user's tasks against a search box query and highlights the matched words
+// q takes words, "quoted phrases", -excluded words, or between alternatives and word* prefixes
+func (app *application) searchTasksHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Query    string
+		Language string
+		data.Filters
+	}
+
+	//Initializing a validator
+	v := validator.New()
+
+	//getting the URL values map
+	qs := r.URL.Query()
+
+	input.Query = app.readString(qs, "q", "")
+	input.Language = app.readString(qs, "language", "simple")
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+
+	//hits always come best match first
+	input.Filters.Sort = "-rank"
+	input.Filters.SortColumns = []string{"rank"}
+
+	//checking for validation errors
+	data.ValidateSearchQuery(v, input.Query)
+	v.Check(validator.In(input.Language, data.SearchLanguages...), "language", "must be one of "+strings.Join(data.SearchLanguages, ", "))
+	if data.ValidateFilter(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	hits, metadata, err := app.models.Tasks.Search(r.Context(), app.taskOwner(r), input.Query, input.Language, input.Filters)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrUnsupportedLanguage):
+			v.AddError("language", "must be simple with this storage backend")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"hits": hits, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// The showTaskOrSearchHandler() method serves GET /v1/todo/:id, httprouter cannot register /v1/todo/search
+// alongside the :id wildcard so the search is picked out here
+func (app *application) showTaskOrSearchHandler(w http.ResponseWriter, r *http.Request) {
+	if httprouter.ParamsFromContext(r.Context()).ByName("id") == "search" {
+		app.searchTasksHandler(w, r)
+		return
+	}
+	app.showTaskHandler(w, r)
+}
+
+// The checkListExists() method adds a list_id validation error when the task's list does not exist
+// a list belonging to another user is reported the same way so its existence is not given away
+// it returns false if a response has already been written because the lookup failed
+func (app *application) checkListExists(w http.ResponseWriter, r *http.Request, v *validator.Validator, task *data.Task) bool {
+	if task.ListID == nil {
+		return true
+	}
+	//the list has to be one of the task owner's own lists
+	_, err := app.models.Lists.Get(r.Context(), *task.ListID, task.UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("list_id", "must refer to an existing list")
+		default:
+			app.serverErrorResponse(w, r, err)
+			return false
+		}
+	}
+	return true
+}
+
+// The checkParent() method adds a parent_id validation error when the task cannot be placed under its parent
+// it returns false if a response has already been written because a lookup failed
+func (app *application) checkParent(w http.ResponseWriter, r *http.Request, v *validator.Validator, task *data.Task) bool {
+	if task.ParentID == nil {
+		return true
+	}
+	//the parent has to be one of the caller's own tasks
+	_, err := app.models.Tasks.Get(r.Context(), *task.ParentID, task.UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("parent_id", "must refer to an existing task")
+			return true
+		default:
+			app.serverErrorResponse(w, r, err)
+			return false
+		}
+	}
+	ancestors, err := app.models.Tasks.Ancestors(r.Context(), *task.ParentID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("parent_id", "must refer to an existing task")
+			return true
+		default:
+			app.serverErrorResponse(w, r, err)
+			return false
+		}
+	}
+
+	//a new task has no subtasks of its own yet
+	height := 1
+	if task.ID != 0 {
+		height, err = app.models.Tasks.SubtreeHeight(r.Context(), task.ID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return false
+		}
+	}
+	data.ValidateParent(v, task, ancestors, height)
+	return true
+}
+
+// The listSubtasks handler shows the direct subtasks of a task
+func (app *application) listSubtasksHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundReponse(w, r)
+		return
+	}
+
+	//making sure the task exists so an unknown id is a 404 rather than an empty listing
+	_, err = app.models.Tasks.Get(r.Context(), id, app.taskOwner(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundReponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	tasks, err := app.models.Tasks.GetSubtasks(r.Context(), id, app.taskOwner(r))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"subtasks": tasks}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,267 @@
+// File: todoApi/backend/cmd/api/handlers_test.go
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"todo.michaelgomez.net/internal/data"
+)
+
+// testMetrics is shared by every test application since newMetrics() can only be called once
+var testMetrics = newMetrics(nil)
+
+// newTestApplication() returns an application with the default configuration and memory storage
+func newTestApplication(t *testing.T) *application {
+	t.Helper()
+
+	cfg, err := loadConfig([]string{"-storage", "memory"}, func(string) string { return "" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &application{
+		config:    cfg,
+		logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+		models:    data.NewMemoryModels(),
+		metrics:   testMetrics,
+		cursorKey: bytes.Repeat([]byte("k"), 32),
+	}
+}
+
+// testUser is the user every test request is made as
+var testUser = &data.User{ID: 1, Name: "test", Email: "test@example.com", Activated: true}
+
+// testTask is the part of a task in a response the tests look at
+type testTask struct {
+	Title string     `json:"title"`
+	DueAt *time.Time `json:"due_at"`
+	Tags  []string   `json:"tags"`
+}
+
+// The serveTest() method routes a request made by testUser to the handler registered at path and returns the status and the decoded body
+func (app *application) serveTest(t *testing.T, handler http.HandlerFunc, method, path, target, body string) (int, map[string]json.RawMessage) {
+	t.Helper()
+
+	router := httprouter.New()
+	router.HandlerFunc(method, path, handler)
+
+	r := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+	r = app.contextSetUser(r, testUser)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	var response map[string]json.RawMessage
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	if err != nil {
+		t.Fatalf("decoding %q: %v", w.Body.String(), err)
+	}
+	return w.Code, response
+}
+
+func TestCreateTaskDueAt(t *testing.T) {
+	tests := []struct {
+		dueAt string
+		want  time.Time
+	}{
+		{dueAt: "2026-10-18T09:00:00-06:00", want: time.Date(2026, 10, 18, 15, 0, 0, 0, time.UTC)},
+		{dueAt: "2026-10-18T09:00:00.25Z", want: time.Date(2026, 10, 18, 9, 0, 0, 250000000, time.UTC)},
+		{dueAt: "2026-10-18T09:00Z", want: time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)},
+		{dueAt: "2026-10-18T09:00+02:00", want: time.Date(2026, 10, 18, 7, 0, 0, 0, time.UTC)},
+		{dueAt: "2026-10-18T09:00:30", want: time.Date(2026, 10, 18, 9, 0, 30, 0, time.UTC)},
+		{dueAt: "2026-10-18T09:00", want: time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)},
+		{dueAt: "2026-10-18", want: time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dueAt, func(t *testing.T) {
+			app := newTestApplication(t)
+			body := `{"title": "buy milk", "description": "on the way home", "due_at": "` + tt.dueAt + `"}`
+			status, response := app.serveTest(t, app.createTaskHandler, http.MethodPost, "/v1/todo", "/v1/todo", body)
+			if status != http.StatusCreated {
+				t.Fatalf("status = %d, want %d: %s", status, http.StatusCreated, response["error"])
+			}
+
+			var task testTask
+			err := json.Unmarshal(response["task"], &task)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if task.DueAt == nil || !task.DueAt.Equal(tt.want) {
+				t.Errorf("due_at = %v, want %v", task.DueAt, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateTaskInvalidDueAt(t *testing.T) {
+	for _, dueAt := range []string{"18/10/2026", "2026-10-18 09:00", "2026-13-01", "tomorrow"} {
+		t.Run(dueAt, func(t *testing.T) {
+			app := newTestApplication(t)
+			body := `{"title": "buy milk", "description": "on the way home", "due_at": "` + dueAt + `"}`
+			status, response := app.serveTest(t, app.createTaskHandler, http.MethodPost, "/v1/todo", "/v1/todo", body)
+			if status != http.StatusUnprocessableEntity {
+				t.Fatalf("status = %d, want %d", status, http.StatusUnprocessableEntity)
+			}
+
+			var errors map[string]string
+			err := json.Unmarshal(response["error"], &errors)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if errors["due_at"] == "" {
+				t.Errorf("no due_at error in %v", errors)
+			}
+		})
+	}
+}
+
+func TestListTasksDueDates(t *testing.T) {
+	app := newTestApplication(t)
+	for _, dueAt := range []string{"2026-10-17T23:30:00Z", "2026-10-18T09:00:00Z", "2026-10-19T00:00:00Z"} {
+		body := `{"title": "due ` + dueAt + `", "description": "x", "due_at": "` + dueAt + `"}`
+		status, _ := app.serveTest(t, app.createTaskHandler, http.MethodPost, "/v1/todo", "/v1/todo", body)
+		if status != http.StatusCreated {
+			t.Fatalf("creating the task due %s: status = %d", dueAt, status)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		//the tasks due today, with a date alone and with times without seconds or an offset
+		{query: "due_after=2026-10-18&due_before=2026-10-19", want: []string{"due 2026-10-18T09:00:00Z"}},
+		{query: "due_after=2026-10-18T00:00Z&due_before=2026-10-19T00:00Z", want: []string{"due 2026-10-18T09:00:00Z"}},
+		{query: "due_after=2026-10-18T00:00&due_before=2026-10-18T23:59:59.999", want: []string{"due 2026-10-18T09:00:00Z"}},
+		{query: "due_before=2026-10-18", want: []string{"due 2026-10-17T23:30:00Z"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			status, response := app.serveTest(t, app.listTasksHandler, http.MethodGet, "/v1/todo", "/v1/todo?"+tt.query, "")
+			if status != http.StatusOK {
+				t.Fatalf("status = %d, want %d: %s", status, http.StatusOK, response["error"])
+			}
+
+			var tasks []testTask
+			err := json.Unmarshal(response["tasks"], &tasks)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var titles []string
+			for _, task := range tasks {
+				titles = append(titles, task.Title)
+			}
+			if len(titles) != len(tt.want) || (len(titles) > 0 && titles[0] != tt.want[0]) {
+				t.Errorf("tasks = %v, want %v", titles, tt.want)
+			}
+		})
+	}
+}
+
+func TestTaskTagsSorted(t *testing.T) {
+	app := newTestApplication(t)
+	want := func(t *testing.T, status, wantStatus int, response map[string]json.RawMessage, tags ...string) {
+		t.Helper()
+		if status != wantStatus {
+			t.Fatalf("status = %d, want %d: %s", status, wantStatus, response["error"])
+		}
+		var task testTask
+		err := json.Unmarshal(response["task"], &task)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(task.Tags, tags) {
+			t.Errorf("tags = %v, want %v", task.Tags, tags)
+		}
+	}
+
+	//every response has the tags in the order reads return them, whatever order they were sent in
+	status, response := app.serveTest(t, app.createTaskHandler, http.MethodPost, "/v1/todo", "/v1/todo",
+		`{"title": "weekend", "description": "x", "tags": ["work", "home", "errands"]}`)
+	want(t, status, http.StatusCreated, response, "errands", "home", "work")
+
+	status, response = app.serveTest(t, app.showTaskHandler, http.MethodGet, "/v1/todo/:id", "/v1/todo/1", "")
+	want(t, status, http.StatusOK, response, "errands", "home", "work")
+
+	status, response = app.serveTest(t, app.updateTaskHandler, http.MethodPut, "/v1/todo/:id", "/v1/todo/1",
+		`{"tags": ["zoo", "garden", "home"]}`)
+	want(t, status, http.StatusOK, response, "garden", "home", "zoo")
+
+	status, response = app.serveTest(t, app.showTaskHandler, http.MethodGet, "/v1/todo/:id", "/v1/todo/1", "")
+	want(t, status, http.StatusOK, response, "garden", "home", "zoo")
+}
+
+func TestUpdateTaskMoveCycle(t *testing.T) {
+	for _, storage := range []string{"memory", "sqlite"} {
+		t.Run(storage, func(t *testing.T) {
+			app := newTestApplication(t)
+			if storage == "sqlite" {
+				app.config.sqlite.path = filepath.Join(t.TempDir(), "todo.db")
+				db, err := openSQLite(app.config)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer db.Close()
+				migrator, err := newMigrator(db, storage, app.logger)
+				if err == nil {
+					err = migrator.Up(context.Background())
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				app.models = data.NewSQLiteModels(db, 3*time.Second)
+			}
+			ctx := context.Background()
+
+			user := &data.User{Name: "test", Email: "test@example.com"}
+			err := user.Password.Set("pa55word123")
+			if err == nil {
+				err = app.models.Users.Insert(ctx, user)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			a := &data.Task{Title: "a", Descritpion: "x", UserID: user.ID}
+			b := &data.Task{Title: "b", Descritpion: "x", UserID: user.ID}
+			for _, task := range []*data.Task{a, b} {
+				err := app.models.Tasks.Insert(ctx, task)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			//both moves pass the handler's check when they run side by side, the store has to stop the second one
+			a.ParentID = &b.ID
+			err = app.models.Tasks.Update(ctx, a, data.SubtasksBlock)
+			if err != nil {
+				t.Fatalf("moving a under b: %v", err)
+			}
+			b.ParentID = &a.ID
+			err = app.models.Tasks.Update(ctx, b, data.SubtasksBlock)
+			if !errors.Is(err, data.ErrParentCycle) {
+				t.Fatalf("moving b under a: err = %v, want %v", err, data.ErrParentCycle)
+			}
+
+			stored, err := app.models.Tasks.Get(ctx, b.ID, user.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.ParentID != nil {
+				t.Errorf("b was moved under %d", *stored.ParentID)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+// File: todoApi/backend/cmd/api/healthcheck.go
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"todo.michaelgomez.net/internal/data"
+)
+
+// readinessTimeout bounds the dependency checks so a dead database fails the probe quickly
+const readinessTimeout = time.Second
+
+// The healthcheck handler reports that the process is up along with the environment and build version
+func (app *application) healthcheckHandler(w http.ResponseWriter, r *http.Request) {
+	env := envelope{
+		"status": "available",
+		"system_info": map[string]string{
+			"environment": app.config.env,
+			"version":     version,
+		},
+	}
+
+	err := app.writeJSON(w, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// The readiness handler checks the database and the schema version
+// a 503 tells the orchestrator to stop routing traffic to this instance
+func (app *application) readinessHandler(w http.ResponseWriter, r *http.Request) {
+	ready := true
+	database := map[string]interface{}{"status": "up"}
+	migrations := map[string]interface{}{"status": "up"}
+
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	err := app.models.Health.Ping(ctx)
+	if err != nil {
+		app.logError(r, err)
+		ready = false
+		database["status"] = "down"
+		migrations["status"] = "unknown"
+	} else {
+		status, err := app.models.Health.MigrationVersion(ctx)
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			//nothing has been migrated yet
+			ready = false
+			migrations["status"] = "down"
+			migrations["version"] = nil
+		case err != nil:
+			app.logError(r, err)
+			ready = false
+			migrations["status"] = "unknown"
+		default:
+			migrations["version"] = status.Version
+			migrations["dirty"] = status.Dirty
+			if status.Dirty {
+				ready = false
+				migrations["status"] = "dirty"
+			}
+		}
+	}
+
+	status, code := "ready", http.StatusOK
+	if !ready {
+		status, code = "unavailable", http.StatusServiceUnavailable
+	}
+	env := envelope{
+		"status": status,
+		"checks": map[string]interface{}{
+			"database":   database,
+			"migrations": migrations,
+		},
+	}
+
+	err = app.writeJSON(w, code, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
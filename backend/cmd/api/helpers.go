@@ -6,10 +6,13 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
+	"runtime/debug"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/julienschmidt/httprouter"
 	"todo.michaelgomez.net/internal/validator"
@@ -124,7 +127,7 @@ func (app *application) readCSV(qs url.Values, key string, defaultValue []string
 	//Get the value
 	value := qs.Get(key)
 	if value == "" {
-		return strings.Split(value, ",")
+		return defaultValue
 	}
 
 	//Split the string based on the "," delimiter
@@ -165,3 +168,169 @@ func (app *application) readBool(qs url.Values, key string, defaultValue bool, v
 	}
 	return boolValue
 }
+
+// The readOptionalBool() method converts a string value from the query to a bool value, nil is returned when it is missing
+// if the value cannot be converted to a boolean then a validation error is added to the validation errors map
+func (app *application) readOptionalBool(qs url.Values, key string, v *validator.Validator) *bool {
+	//getting the value
+	value := qs.Get(key)
+	if value == "" {
+		return nil
+	}
+
+	//Performing the conversion to an boolean
+	boolValue, err := strconv.ParseBool(value)
+	if err != nil {
+		v.AddError(key, "must be a boolean value")
+		return nil
+	}
+	return &boolValue
+}
+
+// The readIDs() method converts a comma separated list of ids from the query string to a slice of integers
+// if any of them is not a positive interger then a validation error is added to the validation errors map
+func (app *application) readIDs(qs url.Values, key string, v *validator.Validator) []int64 {
+	var ids []int64
+	for _, value := range app.readCSV(qs, key, []string{}) {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || id < 1 {
+			v.AddError(key, "must be a comma separated list of ids")
+			return nil
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// The readTime() method converts an ISO-8601 value from the query string to a time value, see parseTime()
+// if the value cannot be parsed then a validation error is added to the validation errors map and nil is returned
+func (app *application) readTime(qs url.Values, key string, v *validator.Validator) *time.Time {
+	//getting the value
+	value := qs.Get(key)
+	if value == "" {
+		return nil
+	}
+	return app.parseTime(value, key, v)
+}
+
+// timeLayouts are the ISO-8601 forms parseTime() accepts, the ones without an offset are read as UTC
+var timeLayouts = []string{
+	time.RFC3339Nano,         //2022-11-03T17:00:00.5-06:00, the fraction is optional
+	"2006-01-02T15:04Z07:00", //without seconds
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	time.DateOnly, //the start of the day
+}
+
+// The parseTime() method converts an ISO-8601 timestamp such as 2022-11-03T17:00:00-06:00 or a date such as 2022-11-03 to a time value
+// if the value cannot be parsed then a validation error is added to the validation errors map and nil is returned
+func (app *application) parseTime(value string, key string, v *validator.Validator) *time.Time {
+	for _, layout := range timeLayouts {
+		t, err := time.Parse(layout, value)
+		if err == nil {
+			return &t
+		}
+	}
+	v.AddError(key, "must be an ISO-8601 date or timestamp (e.g. 2006-01-02 or 2006-01-02T15:04:05Z07:00)")
+	return nil
+}
+
+// The background() method runs fn in a new goroutine that is tracked by the wait group
+// a panic in fn is logged rather than taking down the whole server
+func (app *application) background(fn func()) {
+	app.wg.Add(1)
+
+	go func() {
+		defer app.wg.Done()
+
+		defer func() {
+			if err := recover(); err != nil {
+				app.logger.Error(fmt.Sprintf("%s", err), "source", "background", "stack", string(debug.Stack()))
+			}
+		}()
+
+		fn()
+	}()
+}
+
+// splitList() splits a comma separated setting, dropping the blank entries
+func splitList(list string) []string {
+	var entries []string
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// parseTrustedProxies() turns a comma separated list of IP addresses and CIDR ranges into networks
+func parseTrustedProxies(list string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, entry := range splitList(list) {
+		//a bare address is a network containing only itself
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address %q", entry)
+			}
+			if ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, network)
+	}
+	return nets, nil
+}
+
+// isTrusted() reports whether the address belongs to one of the trusted networks
+func isTrusted(ip net.IP, trusted []*net.IPNet) bool {
+	for _, network := range trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP() returns the address of the client that made the request
+// the X-Forwarded-For and X-Real-IP headers are only believed when the connection comes from a trusted proxy
+// X-Forwarded-For is read right to left and the first address that is not a trusted proxy is the client
+func clientIP(r *http.Request, trusted []*net.IPNet) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	remote := net.ParseIP(host)
+	if remote == nil || !isTrusted(remote, trusted) {
+		return host
+	}
+
+	if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+		hops := strings.Split(strings.Join(forwarded, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			ip := net.ParseIP(strings.TrimSpace(hops[i]))
+			if ip == nil {
+				//whatever is left of a malformed entry cannot be trusted
+				break
+			}
+			if !isTrusted(ip, trusted) || i == 0 {
+				return ip.String()
+			}
+		}
+	}
+
+	if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ip != nil {
+		return ip.String()
+	}
+	return host
+}
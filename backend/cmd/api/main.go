@@ -3,83 +3,175 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
 	"database/sql"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
-	"net/http"
+	"io"
+	"log/slog"
 	"os"
+	"sync"
 	"time"
 
 	_ "github.com/lib/pq"
 	"todo.michaelgomez.net/internal/data"
+	"todo.michaelgomez.net/internal/mailer"
 )
 
-// configuration struct to hold configuration settings
-type config struct {
-	port int      //port on which the databased will open on
-	env  string   //which environment we are working in (for this quiz it'll be development)
-	db   struct { //database limiters and dependencies
-		dsn          string //connection to databases
-		maxOpenConns int    //limit of open connections
-		maxIdleConns int    //limit of idle connections
-		MaxIdleTime  string //limit on idle time
-	}
-}
-
 // application struct is made to facilitate dependency injection
 type application struct {
-	config config
-	logger *log.Logger
-	models data.Models
+	config    config
+	logger    *slog.Logger
+	models    data.Models
+	mailer    mailer.Mailer
+	metrics   *metrics
+	cursorKey []byte         //signs the cursors handed out by the task listing
+	wg        sync.WaitGroup //tracks the goroutines started by background()
 }
 
 // main
 func main() {
-	//initializing where the configurations are gonna be stored
-	var cfg config
+	//"api migrate <command>" manages the schema instead of serving requests
+	args := os.Args[1:]
+	var migration *migrateCommand
+	if len(args) > 0 && args[0] == "migrate" {
+		cmd, rest, err := parseMigrateCommand(args[1:])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		migration, args = &cmd, rest
+	}
 
-	//hardcoding cofigurations since they are required for the quiz
-	cfg.port = 4000
-	cfg.env = "development"
-	cfg.db.dsn = os.Getenv("TODO_DB_DSN")
-	cfg.db.maxOpenConns = 25
-	cfg.db.maxIdleConns = 25
-	cfg.db.MaxIdleTime = "15m"
+	//reading the configuration from the flags, environment and config file
+	cfg, err := loadConfig(args, os.Getenv)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
 
-	//creating logger to log issues or state changes
-	logger := log.New(os.Stdout, "", log.Ldate|log.Ltime)
+	if cfg.cli.version {
+		fmt.Printf("Version:\t%s\n", version)
+		os.Exit(0)
+	}
 
-	//creating connection
-	db, err := openDB(cfg)
+	//an invalid configuration is still printed so it can be inspected
+	err = validateConfig(cfg)
+	if cfg.cli.printConfig {
+		//the logger is not set up yet, so a failed write is reported on stderr like the configuration errors
+		printErr := printConfig(os.Stdout, cfg)
+		if printErr != nil {
+			fmt.Fprintln(os.Stderr, "printing the configuration:", printErr)
+			os.Exit(1)
+		}
+	}
 	if err != nil {
-		logger.Fatal(err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if cfg.cli.printConfig {
+		os.Exit(0)
+	}
+	if migration != nil && cfg.storage == "memory" {
+		fmt.Fprintln(os.Stderr, "migrate needs postgres or sqlite storage")
+		os.Exit(2)
+	}
+
+	//creating logger to log issues or state changes
+	logger := newLogger(os.Stdout, cfg)
+
+	//only the models of the selected storage are built, the memory storage needs no database so db stays nil
+	var (
+		db     *sql.DB
+		models data.Models
+	)
+	switch cfg.storage {
+	case "memory":
+		models = data.NewMemoryModels()
+
+		logger.Warn("using memory storage, nothing is kept once the server stops")
+	case "sqlite":
+		db, err = openSQLite(cfg)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+		models = data.NewSQLiteModels(db, cfg.db.queryTimeout)
+
+		logger.Info("sqlite database opened", "path", cfg.sqlite.path)
+	default:
+		//creating connection
+		db, err = openDB(cfg)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+		models = data.NewModels(db, cfg.db.queryTimeout)
+
+		logger.Info("database connection pool established")
 	}
 
-	//ensuring that the connection to the database is closed
-	defer db.Close()
+	if migration != nil {
+		err = runMigrateCommand(context.Background(), db, cfg.storage, logger, *migration, os.Stdout)
+		db.Close()
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	//bringing the schema up to date before serving, the advisory lock keeps instances starting together from racing
+	//a sqlite database is only ever used by this process so it is always brought up to date
+	if cfg.db.autoMigrate || cfg.storage == "sqlite" {
+		migrator, err := newMigrator(db, cfg.storage, logger)
+		if err == nil {
+			err = migrator.Up(context.Background())
+		}
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+	}
 
-	logger.Println("database connection pool established")
+	//emails are written to the terminal during development when there is no mail server
+	var m mailer.Mailer = mailer.NewLog(os.Stdout, cfg.smtp.sender)
+	if cfg.smtp.host != "" {
+		m = mailer.NewSMTP(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender)
+	}
+
+	//cursors have to be signed with the same key by every instance and across restarts to keep working
+	cursorKey := []byte(cfg.cursor.secret)
+	if len(cursorKey) == 0 {
+		cursorKey = make([]byte, 32)
+		_, err = rand.Read(cursorKey)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+		logger.Warn("no cursor-secret set, pagination cursors will stop working when the server restarts")
+	}
 
 	//initializing the app struct
 	app := &application{
-		config: cfg,
-		logger: logger,
-		models: data.NewModels(db),
+		config:    cfg,
+		logger:    logger,
+		models:    models,
+		mailer:    m,
+		metrics:   newMetrics(db),
+		cursorKey: cursorKey,
 	}
 
-	//initializing http server dependencies
-	srv := &http.Server{
-		Addr:         fmt.Sprintf(":%d", cfg.port),
-		Handler:      app.routes(),
-		IdleTimeout:  time.Minute,
-		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 30 * time.Second,
+	//serving until the process is asked to stop
+	err = app.serve(db)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
 	}
-
-	//staring the web server
-	logger.Printf("starting %s server on %s", cfg.env, srv.Addr)
-	err = srv.ListenAndServe()
-	logger.Fatal(err)
 }
 
 // OpenDB() function returns a *sql.DB connection pool
@@ -90,11 +182,7 @@ func openDB(cfg config) (*sql.DB, error) {
 	}
 	db.SetMaxOpenConns(cfg.db.maxOpenConns)
 	db.SetMaxIdleConns(cfg.db.maxIdleConns)
-	duration, err := time.ParseDuration(cfg.db.MaxIdleTime)
-	if err != nil {
-		return nil, err
-	}
-	db.SetConnMaxIdleTime(duration)
+	db.SetConnMaxIdleTime(cfg.db.maxIdleTime)
 
 	//Creating a context with a 5-second timeout deadline
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -106,3 +194,12 @@ func openDB(cfg config) (*sql.DB, error) {
 	}
 	return db, nil
 }
+
+// The newLogger() function returns a leveled logger writing JSON or text records to out
+func newLogger(out io.Writer, cfg config) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: cfg.log.level}
+	if cfg.log.format == "text" {
+		return slog.New(slog.NewTextHandler(out, opts))
+	}
+	return slog.New(slog.NewJSONHandler(out, opts))
+}
@@ -0,0 +1,307 @@
+// File: todoApi/backend/cmd/api/metrics.go
+package main
+
+import (
+	"database/sql"
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// latencyBuckets are the upper bounds, in seconds, of the request duration histogram
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// requestKey identifies one series of the request metrics
+type requestKey struct {
+	method string
+	route  string
+	status int
+}
+
+// requestStats is the request count and latency histogram of one series
+type requestStats struct {
+	count   int64
+	sum     float64
+	buckets []int64 //cumulative counts, one per latency bucket
+}
+
+// metrics collects what the API reports on /metrics and /debug/metrics
+type metrics struct {
+	db    *sql.DB
+	start time.Time
+
+	mu       sync.Mutex
+	requests map[requestKey]*requestStats
+
+	inFlight       atomic.Int64
+	tasksCreated   atomic.Int64
+	tasksCompleted atomic.Int64
+	tasksDeleted   atomic.Int64
+}
+
+// The newMetrics() function returns an empty set of metrics and publishes them with expvar
+// it must only be called once since expvar names cannot be published twice, db is nil with memory storage
+func newMetrics(db *sql.DB) *metrics {
+	m := &metrics{
+		db:       db,
+		start:    time.Now(),
+		requests: make(map[requestKey]*requestStats),
+	}
+
+	expvar.NewString("version").Set(version)
+	expvar.Publish("goroutines", expvar.Func(func() interface{} {
+		return runtime.NumGoroutine()
+	}))
+	if db != nil {
+		expvar.Publish("database", expvar.Func(func() interface{} {
+			return db.Stats()
+		}))
+	}
+	expvar.Publish("requests_in_flight", expvar.Func(func() interface{} {
+		return m.inFlight.Load()
+	}))
+	expvar.Publish("requests", expvar.Func(func() interface{} {
+		return m.requestCounts()
+	}))
+	expvar.Publish("tasks", expvar.Func(func() interface{} {
+		return map[string]int64{
+			"created":   m.tasksCreated.Load(),
+			"completed": m.tasksCompleted.Load(),
+			"deleted":   m.tasksDeleted.Load(),
+		}
+	}))
+
+	return m
+}
+
+// observe() records a finished request
+func (m *metrics) observe(key requestKey, duration time.Duration) {
+	seconds := duration.Seconds()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats, ok := m.requests[key]
+	if !ok {
+		stats = &requestStats{buckets: make([]int64, len(latencyBuckets))}
+		m.requests[key] = stats
+	}
+	stats.count++
+	stats.sum += seconds
+	for i, bound := range latencyBuckets {
+		if seconds <= bound {
+			stats.buckets[i]++
+		}
+	}
+}
+
+// requestCounts() returns the number of requests per "METHOD route status"
+func (m *metrics) requestCounts() map[string]int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	counts := make(map[string]int64, len(m.requests))
+	for key, stats := range m.requests {
+		counts[fmt.Sprintf("%s %s %d", key.method, key.route, key.status)] = stats.count
+	}
+	return counts
+}
+
+// snapshot() returns a copy of every request series sorted so the output is stable
+func (m *metrics) snapshot() ([]requestKey, map[requestKey]requestStats) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keys := make([]requestKey, 0, len(m.requests))
+	stats := make(map[requestKey]requestStats, len(m.requests))
+	for key, s := range m.requests {
+		keys = append(keys, key)
+		stats[key] = requestStats{count: s.count, sum: s.sum, buckets: append([]int64(nil), s.buckets...)}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].route != keys[j].route {
+			return keys[i].route < keys[j].route
+		}
+		if keys[i].method != keys[j].method {
+			return keys[i].method < keys[j].method
+		}
+		return keys[i].status < keys[j].status
+	})
+	return keys, stats
+}
+
+// The writePrometheus() method writes the metrics in the Prometheus text exposition format
+func (m *metrics) writePrometheus(w io.Writer) {
+	keys, stats := m.snapshot()
+
+	writeHeader(w, "todo_http_requests_total", "counter", "Number of HTTP requests by method, route and status code.")
+	for _, key := range keys {
+		fmt.Fprintf(w, "todo_http_requests_total{%s} %d\n", key.labels(), stats[key].count)
+	}
+
+	writeHeader(w, "todo_http_request_duration_seconds", "histogram", "HTTP request latency by method, route and status code.")
+	for _, key := range keys {
+		s := stats[key]
+		labels := key.labels()
+		for i, bound := range latencyBuckets {
+			fmt.Fprintf(w, "todo_http_request_duration_seconds_bucket{%s,le=\"%s\"} %d\n", labels, formatFloat(bound), s.buckets[i])
+		}
+		fmt.Fprintf(w, "todo_http_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, s.count)
+		fmt.Fprintf(w, "todo_http_request_duration_seconds_sum{%s} %s\n", labels, formatFloat(s.sum))
+		fmt.Fprintf(w, "todo_http_request_duration_seconds_count{%s} %d\n", labels, s.count)
+	}
+
+	writeHeader(w, "todo_http_requests_in_flight", "gauge", "Number of HTTP requests being served.")
+	fmt.Fprintf(w, "todo_http_requests_in_flight %d\n", m.inFlight.Load())
+
+	writeHeader(w, "todo_tasks_created_total", "counter", "Number of tasks created.")
+	fmt.Fprintf(w, "todo_tasks_created_total %d\n", m.tasksCreated.Load())
+	writeHeader(w, "todo_tasks_completed_total", "counter", "Number of tasks marked as completed.")
+	fmt.Fprintf(w, "todo_tasks_completed_total %d\n", m.tasksCompleted.Load())
+	writeHeader(w, "todo_tasks_deleted_total", "counter", "Number of tasks deleted.")
+	fmt.Fprintf(w, "todo_tasks_deleted_total %d\n", m.tasksDeleted.Load())
+
+	//the connection pool opened by openDB()
+	if m.db != nil {
+		m.writeDBStats(w)
+	}
+
+	//Go runtime
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	writeHeader(w, "todo_go_goroutines", "gauge", "Number of goroutines.")
+	fmt.Fprintf(w, "todo_go_goroutines %d\n", runtime.NumGoroutine())
+	writeHeader(w, "todo_go_heap_alloc_bytes", "gauge", "Bytes of allocated heap objects.")
+	fmt.Fprintf(w, "todo_go_heap_alloc_bytes %d\n", mem.HeapAlloc)
+	writeHeader(w, "todo_go_sys_bytes", "gauge", "Bytes of memory obtained from the OS.")
+	fmt.Fprintf(w, "todo_go_sys_bytes %d\n", mem.Sys)
+	writeHeader(w, "todo_go_gc_runs_total", "counter", "Number of completed GC cycles.")
+	fmt.Fprintf(w, "todo_go_gc_runs_total %d\n", mem.NumGC)
+	writeHeader(w, "todo_go_gc_pause_seconds_total", "counter", "Total time the GC stopped the world.")
+	fmt.Fprintf(w, "todo_go_gc_pause_seconds_total %s\n", formatFloat(time.Duration(mem.PauseTotalNs).Seconds()))
+
+	writeHeader(w, "todo_uptime_seconds", "gauge", "Seconds since the server started.")
+	fmt.Fprintf(w, "todo_uptime_seconds %s\n", formatFloat(time.Since(m.start).Seconds()))
+}
+
+// writeHeader() writes the HELP and TYPE lines that introduce a metric
+func writeHeader(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// formatFloat() formats a sample value
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// writeDBStats() writes the statistics of the connection pool
+func (m *metrics) writeDBStats(w io.Writer) {
+	db := m.db.Stats()
+	writeHeader(w, "todo_db_open_connections", "gauge", "Established database connections, in use and idle.")
+	fmt.Fprintf(w, "todo_db_open_connections %d\n", db.OpenConnections)
+	writeHeader(w, "todo_db_in_use_connections", "gauge", "Database connections currently in use.")
+	fmt.Fprintf(w, "todo_db_in_use_connections %d\n", db.InUse)
+	writeHeader(w, "todo_db_idle_connections", "gauge", "Idle database connections.")
+	fmt.Fprintf(w, "todo_db_idle_connections %d\n", db.Idle)
+	writeHeader(w, "todo_db_max_open_connections", "gauge", "Maximum number of open database connections.")
+	fmt.Fprintf(w, "todo_db_max_open_connections %d\n", db.MaxOpenConnections)
+	writeHeader(w, "todo_db_wait_count_total", "counter", "Number of times a request waited for a database connection.")
+	fmt.Fprintf(w, "todo_db_wait_count_total %d\n", db.WaitCount)
+	writeHeader(w, "todo_db_wait_duration_seconds_total", "counter", "Time spent waiting for a database connection.")
+	fmt.Fprintf(w, "todo_db_wait_duration_seconds_total %s\n", formatFloat(db.WaitDuration.Seconds()))
+	writeHeader(w, "todo_db_max_idle_closed_total", "counter", "Connections closed because of db-max-idle-conns.")
+	fmt.Fprintf(w, "todo_db_max_idle_closed_total %d\n", db.MaxIdleClosed)
+	writeHeader(w, "todo_db_max_idle_time_closed_total", "counter", "Connections closed because of db-max-idle-time.")
+	fmt.Fprintf(w, "todo_db_max_idle_time_closed_total %d\n", db.MaxIdleTimeClosed)
+}
+
+// labels() formats the key as Prometheus labels
+func (key requestKey) labels() string {
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return fmt.Sprintf(`method="%s",route="%s",status="%d"`, escape.Replace(key.method), escape.Replace(key.route), key.status)
+}
+
+// knownMethods keeps the method label from growing with whatever clients send
+var knownMethods = map[string]bool{
+	http.MethodGet: true, http.MethodHead: true, http.MethodPost: true, http.MethodPut: true,
+	http.MethodPatch: true, http.MethodDelete: true, http.MethodOptions: true,
+}
+
+// routePattern() returns the router pattern, e.g. /v1/todo/:id, the request matched
+// requests that match no route share a single label so they cannot blow up the number of series
+func routePattern(router *httprouter.Router, r *http.Request) string {
+	handle, params, _ := router.Lookup(r.Method, r.URL.Path)
+	if handle == nil {
+		return "unmatched"
+	}
+
+	//putting the parameter names back in place of their values, a segment is only a parameter
+	//if the router still captures it after it has been swapped for a placeholder
+	//so /v1/todo/v1 becomes /v1/todo/:id rather than /:id/todo/v1
+	const placeholder = "-"
+	segments := strings.Split(r.URL.Path, "/")
+	next := 0
+	for i, segment := range segments {
+		if next == len(params) {
+			break
+		}
+		if segment != params[next].Value {
+			continue
+		}
+
+		segments[i] = placeholder
+		_, probe, _ := router.Lookup(r.Method, strings.Join(segments, "/"))
+		if next < len(probe) && probe[next].Value == placeholder {
+			segments[i] = ":" + params[next].Key
+			next++
+		} else {
+			segments[i] = segment
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// The collectMetrics() middleware counts every request and times it, labelled with the first of the routers that matches it
+func (app *application) collectMetrics(routers []*httprouter.Router, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		app.metrics.inFlight.Add(1)
+		defer app.metrics.inFlight.Add(-1)
+
+		sr := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(sr, r)
+		if sr.status == 0 {
+			sr.status = http.StatusOK
+		}
+
+		method := r.Method
+		if !knownMethods[method] {
+			method = "OTHER"
+		}
+		route := "unmatched"
+		for _, router := range routers {
+			if route = routePattern(router, r); route != "unmatched" {
+				break
+			}
+		}
+		key := requestKey{method: method, route: route, status: sr.status}
+		app.metrics.observe(key, time.Since(start))
+	})
+}
+
+// The metricsHandler() method serves the metrics in the Prometheus text format
+func (app *application) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	app.metrics.writePrometheus(w)
+}
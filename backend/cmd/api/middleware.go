@@ -0,0 +1,293 @@
+// File: todoApi/backend/cmd/api/middleware.go
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"todo.michaelgomez.net/internal/data"
+	"todo.michaelgomez.net/internal/validator"
+)
+
+// statusRecorder wraps a ResponseWriter to remember the status code that was sent
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sr *statusRecorder) WriteHeader(status int) {
+	if sr.status == 0 {
+		sr.status = status
+	}
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+func (sr *statusRecorder) Write(b []byte) (int, error) {
+	if sr.status == 0 {
+		sr.status = http.StatusOK
+	}
+	return sr.ResponseWriter.Write(b)
+}
+
+// newRequestID() returns a random identifier for requests that did not arrive with one
+func newRequestID() string {
+	b := make([]byte, 8)
+	_, err := rand.Read(b)
+	if err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// The logRequest() middleware tags the request with an ID and logs it once the response has been written
+// an X-Request-ID sent by a proxy is kept so the logs can be correlated
+func (app *application) logRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		info := &requestInfo{id: r.Header.Get("X-Request-ID")}
+		if info.id == "" || len(info.id) > 128 {
+			info.id = newRequestID()
+		}
+		w.Header().Set("X-Request-ID", info.id)
+		r = app.contextSetRequestInfo(r, info)
+
+		sr := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(sr, r)
+		if sr.status == 0 {
+			sr.status = http.StatusOK
+		}
+
+		attrs := append(app.requestAttrs(r), "status", sr.status, "duration", time.Since(start))
+		app.logger.Info("request completed", attrs...)
+	})
+}
+
+// The recoverPanic() middleware turns a panic in a handler into the standard 500 response
+// the connection is closed afterwards since the handler may have left it in an unknown state
+func (app *application) recoverPanic(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if rec := recover(); rec != nil {
+				//http.ErrAbortHandler is how a handler asks net/http to abort the response silently
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
+
+				w.Header().Set("Connection", "close")
+				app.serverErrorResponse(w, r, panicError{value: rec, stack: debug.Stack()})
+			}
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// The enableCORS() middleware lets the browsers on the trusted origins call the API
+// preflight requests are answered here so they never reach the router or the rate limiter
+func (app *application) enableCORS(next http.Handler) http.Handler {
+	trustedOrigins := splitList(app.config.cors.trustedOrigins)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		//the response depends on the Origin and preflight headers so caches must keep them apart
+		w.Header().Add("Vary", "Origin")
+		w.Header().Add("Vary", "Access-Control-Request-Method")
+
+		origin := r.Header.Get("Origin")
+		trusted := false
+		for _, trustedOrigin := range trustedOrigins {
+			if origin != "" && origin == trustedOrigin {
+				trusted = true
+				break
+			}
+		}
+
+		if trusted {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Expose-Headers", "Retry-After, X-Request-ID")
+
+			//a preflight asks whether a PUT, PATCH or DELETE with an Authorization header may be sent
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				w.Header().Set("Access-Control-Allow-Methods", "OPTIONS, PUT, PATCH, DELETE")
+				w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+				w.Header().Set("Access-Control-Max-Age", "600")
+
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// The authenticate() middleware identifies the client from its bearer token and adds the user to the request context
+// requests without an Authorization header carry on as the anonymous user
+func (app *application) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		//the response depends on the Authorization header so caches must keep them apart
+		w.Header().Add("Vary", "Authorization")
+
+		authorizationHeader := r.Header.Get("Authorization")
+		if authorizationHeader == "" {
+			r = app.contextSetUser(r, data.AnonymousUser)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		//expecting a header of the form "Bearer <token>"
+		headerParts := strings.Split(authorizationHeader, " ")
+		if len(headerParts) != 2 || headerParts[0] != "Bearer" {
+			app.invalidAuthenticationTokenResponse(w, r)
+			return
+		}
+		token := headerParts[1]
+
+		v := validator.New()
+		if data.ValidateTokenPlaintext(v, token); !v.Valid() {
+			app.invalidAuthenticationTokenResponse(w, r)
+			return
+		}
+
+		user, err := app.models.Users.GetForToken(r.Context(), data.ScopeAuthentication, token)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.invalidAuthenticationTokenResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		r = app.contextSetUser(r, user)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// The rateLimit() middleware gives every client IP address a token bucket of limiter-burst requests refilled at limiter-rps
+// it runs before authenticate() so that requests carrying made-up tokens are turned away before they cost a database lookup
+// the goroutine evicting idle clients stops when ctx is cancelled
+func (app *application) rateLimit(ctx context.Context, next http.Handler) http.Handler {
+	if !app.config.limiter.enabled {
+		return next
+	}
+
+	type client struct {
+		limiter  *rate.Limiter
+		lastSeen time.Time
+	}
+
+	var (
+		mu      sync.Mutex
+		clients = make(map[string]*client)
+	)
+
+	//validateConfig() has already checked the list
+	trusted, _ := parseTrustedProxies(app.config.limiter.trustedProxies)
+
+	//evicting the clients that have not been seen for a while so the map does not grow forever
+	app.background(func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			mu.Lock()
+			for key, c := range clients {
+				if time.Since(c.lastSeen) > 3*time.Minute {
+					delete(clients, key)
+				}
+			}
+			mu.Unlock()
+		}
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := clientIP(r, trusted)
+
+		mu.Lock()
+		c, found := clients[key]
+		if !found {
+			c = &client{limiter: rate.NewLimiter(rate.Limit(app.config.limiter.rps), app.config.limiter.burst)}
+			clients[key] = c
+		}
+		c.lastSeen = time.Now()
+
+		//a reservation that has to wait means the bucket is empty, it is handed back so it does not count
+		reservation := c.limiter.Reserve()
+		delay := reservation.Delay()
+		if delay > 0 {
+			reservation.Cancel()
+		}
+		mu.Unlock()
+
+		if delay > 0 {
+			app.rateLimitExceededResponse(w, r, delay)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// The requireAuthenticatedUser() middleware rejects anonymous clients
+func (app *application) requireAuthenticatedUser(next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := app.contextGetUser(r)
+		if user.IsAnonymous() {
+			app.authenticationRequiredResponse(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// The requireActivatedUser() middleware rejects anonymous clients and accounts that have not been activated
+func (app *application) requireActivatedUser(next http.HandlerFunc) http.HandlerFunc {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		user := app.contextGetUser(r)
+		if !user.Activated {
+			app.inactiveAccountResponse(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+	return app.requireAuthenticatedUser(fn)
+}
+
+// The requirePermission() middleware rejects users that have not been granted the permission code
+// the user's permissions are added to the request context for the handler
+func (app *application) requirePermission(code string, next http.HandlerFunc) http.HandlerFunc {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		user := app.contextGetUser(r)
+
+		permissions, err := app.models.Permissions.GetAllForUser(r.Context(), user.ID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		//todo:admin implies every other todo permission
+		if !permissions.Include(code) && !permissions.Include(data.PermissionTodoAdmin) {
+			app.notPermittedResponse(w, r)
+			return
+		}
+
+		r = app.contextSetPermissions(r, permissions)
+		next.ServeHTTP(w, r)
+	}
+	return app.requireActivatedUser(fn)
+}
@@ -0,0 +1,107 @@
+// File: todoApi/backend/cmd/api/migrate.go
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"text/tabwriter"
+
+	"todo.michaelgomez.net/internal/migrate"
+	"todo.michaelgomez.net/migrations"
+)
+
+// migrateUsage lists the migrate subcommands
+const migrateUsage = `usage: api migrate up|down|goto VERSION|force VERSION|status [flags]
+  up              apply every pending migration
+  down            revert the most recently applied migration
+  goto VERSION    apply or revert migrations until VERSION is the latest applied, 0 reverts everything
+  force VERSION   mark VERSION and everything before it as applied without running them, clears a dirty database
+  status          list the migrations and whether they have been applied`
+
+// migrateCommand is a parsed "api migrate" command line
+type migrateCommand struct {
+	name    string
+	version int64
+}
+
+// The parseMigrateCommand() function reads the subcommand following "migrate" and returns the remaining flags
+func parseMigrateCommand(args []string) (migrateCommand, []string, error) {
+	if len(args) == 0 {
+		return migrateCommand{}, nil, errors.New(migrateUsage)
+	}
+
+	cmd := migrateCommand{name: args[0]}
+	args = args[1:]
+	switch cmd.name {
+	case "up", "down", "status":
+	case "goto", "force":
+		if len(args) == 0 {
+			return cmd, nil, fmt.Errorf("migrate %s needs a version\n%s", cmd.name, migrateUsage)
+		}
+		version, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil || version < 0 {
+			return cmd, nil, fmt.Errorf("invalid version %q\n%s", args[0], migrateUsage)
+		}
+		cmd.version = version
+		args = args[1:]
+	default:
+		return cmd, nil, fmt.Errorf("unknown migrate command %q\n%s", cmd.name, migrateUsage)
+	}
+	return cmd, args, nil
+}
+
+// newMigrator() returns a migrator for the migrations compiled into the binary, storage picks the postgres or sqlite set
+func newMigrator(db *sql.DB, storage string, logger *slog.Logger) (*migrate.Migrator, error) {
+	if storage == "sqlite" {
+		return migrate.NewSQLite(db, migrations.SQLite(), logger)
+	}
+	return migrate.New(db, migrations.FS, logger)
+}
+
+// The runMigrateCommand() function carries out a migrate command, status is written to out
+func runMigrateCommand(ctx context.Context, db *sql.DB, storage string, logger *slog.Logger, cmd migrateCommand, out io.Writer) error {
+	migrator, err := newMigrator(db, storage, logger)
+	if err != nil {
+		return err
+	}
+
+	switch cmd.name {
+	case "up":
+		return migrator.Up(ctx)
+	case "down":
+		return migrator.Down(ctx)
+	case "goto":
+		return migrator.Goto(ctx, cmd.version)
+	case "force":
+		return migrator.Force(ctx, cmd.version)
+	}
+
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED AT\tSTATE")
+	for _, s := range statuses {
+		appliedAt, state := "-", "pending"
+		if s.Applied {
+			appliedAt, state = s.AppliedAt.Format("2006-01-02 15:04:05"), "applied"
+		}
+		switch {
+		case s.Dirty:
+			state = "dirty"
+		case s.Missing:
+			state = "unknown to this binary"
+		case s.ChecksumMismatch:
+			state = "changed since applied"
+		}
+		fmt.Fprintf(tw, "%06d\t%s\t%s\t%s\n", s.Version, s.Name, appliedAt, state)
+	}
+	return tw.Flush()
+}
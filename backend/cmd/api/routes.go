@@ -2,12 +2,17 @@
 package main
 
 import (
+	"context"
+	"expvar"
 	"net/http"
 
 	"github.com/julienschmidt/httprouter"
+	"todo.michaelgomez.net/internal/data"
 )
 
-func (app *application) routes() *httprouter.Router {
+// The routes() method returns the handler for every route wrapped in the middleware chain
+// cancelling ctx stops the goroutines the middleware started
+func (app *application) routes(ctx context.Context) http.Handler {
 	router := httprouter.New()
 
 	//security routes
@@ -15,11 +20,44 @@ func (app *application) routes() *httprouter.Router {
 	router.MethodNotAllowed = http.HandlerFunc(app.methodNotAllowedResponse)
 
 	//actual routes
-	router.HandlerFunc(http.MethodGet, "/v1/todo", app.listTasksHandler)
-	router.HandlerFunc(http.MethodPost, "/v1/todo", app.createTaskHandler)
-	router.HandlerFunc(http.MethodGet, "/v1/todo/:id", app.showTaskHandler)
-	router.HandlerFunc(http.MethodPut, "/v1/todo/:id", app.updateTaskHandler)
-	router.HandlerFunc(http.MethodDelete, "/v1/todo/:id", app.deleteTaskHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/todo", app.requirePermission(data.PermissionTodoRead, app.listTasksHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/todo", app.requirePermission(data.PermissionTodoWrite, app.createTaskHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/todo/:id", app.requirePermission(data.PermissionTodoRead, app.showTaskOrSearchHandler)) //and /v1/todo/search
+	router.HandlerFunc(http.MethodPut, "/v1/todo/:id", app.requirePermission(data.PermissionTodoWrite, app.updateTaskHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/todo/:id", app.requirePermission(data.PermissionTodoWrite, app.deleteTaskHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/todo/:id/subtasks", app.requirePermission(data.PermissionTodoRead, app.listSubtasksHandler))
 
-	return router
+	router.HandlerFunc(http.MethodPost, "/v1/users", app.registerUserHandler)
+	router.HandlerFunc(http.MethodPut, "/v1/users/activated", app.activateUserHandler)
+	router.HandlerFunc(http.MethodPut, "/v1/users/password", app.updateUserPasswordHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/tokens/authentication", app.createAuthenticationTokenHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/tokens/password-reset", app.createPasswordResetTokenHandler)
+
+	router.HandlerFunc(http.MethodGet, "/v1/lists", app.requirePermission(data.PermissionTodoRead, app.listListsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/lists", app.requirePermission(data.PermissionTodoWrite, app.createListHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/lists/:id", app.requirePermission(data.PermissionTodoRead, app.showListHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/lists/:id", app.requirePermission(data.PermissionTodoWrite, app.updateListHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/lists/:id", app.requirePermission(data.PermissionTodoWrite, app.deleteListHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/lists/:id/tasks", app.requirePermission(data.PermissionTodoRead, app.listListTasksHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/tags", app.requirePermission(data.PermissionTodoRead, app.listTagsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/tags", app.requirePermission(data.PermissionTodoWrite, app.createTagHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/tags/:id", app.requirePermission(data.PermissionTodoRead, app.showTagHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/tags/:id", app.requirePermission(data.PermissionTodoWrite, app.updateTagHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/tags/:id", app.requirePermission(data.PermissionTodoWrite, app.deleteTagHandler))
+
+	//health checks and metrics are polled by the orchestrator and the scraper, so they get a router of their own in front of
+	//the rate limiter and authentication, anything else falls through to the API routes above
+	probes := httprouter.New()
+	probes.NotFound = app.rateLimit(ctx, app.authenticate(router))
+	probes.MethodNotAllowed = http.HandlerFunc(app.methodNotAllowedResponse)
+
+	probes.HandlerFunc(http.MethodGet, "/v1/healthcheck", app.healthcheckHandler)
+	probes.HandlerFunc(http.MethodGet, "/readyz", app.readinessHandler)
+
+	//metrics, the expvar JSON and the Prometheus text format of the same figures, both served without authentication
+	probes.Handler(http.MethodGet, "/debug/metrics", expvar.Handler())
+	probes.HandlerFunc(http.MethodGet, "/metrics", app.metricsHandler)
+
+	return app.logRequest(app.collectMetrics([]*httprouter.Router{probes, router}, app.recoverPanic(app.enableCORS(probes))))
 }
@@ -0,0 +1,103 @@
+// File: todoApi/backend/cmd/api/server.go
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// The serve() method runs the HTTP server until it receives SIGINT or SIGTERM and then shuts it down gracefully
+// in-flight requests and background goroutines are given until the shutdown timeout to finish, the database pool, if any, is closed last
+func (app *application) serve(db *sql.DB) error {
+	//every request context derives from this one, cancelling it stops the queries of requests still running after the shutdown timeout
+	baseCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+
+	//cancelled once the server has stopped taking requests, it ends the housekeeping goroutines such as the rate limiter's sweeper
+	housekeepingCtx, stopHousekeeping := context.WithCancel(context.Background())
+	defer stopHousekeeping()
+
+	//initializing http server dependencies
+	srv := &http.Server{
+		Addr:         fmt.Sprintf(":%d", app.config.port),
+		Handler:      app.routes(housekeepingCtx),
+		ErrorLog:     slog.NewLogLogger(app.logger.Handler(), slog.LevelError),
+		IdleTimeout:  time.Minute,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 30 * time.Second,
+		BaseContext: func(net.Listener) context.Context {
+			return baseCtx
+		},
+	}
+
+	//receives the outcome of the shutdown once it has completed
+	shutdownError := make(chan error)
+
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+		s := <-quit
+
+		app.logger.Info("caught signal, shutting down server", "signal", s.String())
+
+		ctx, cancel := context.WithTimeout(context.Background(), app.config.shutdownTimeout)
+		defer cancel()
+
+		//stops accepting new connections and waits for the in-flight requests
+		err := srv.Shutdown(ctx)
+		if err != nil {
+			cancelRequests()
+			shutdownError <- err
+			return
+		}
+
+		app.logger.Info("waiting for background tasks to finish")
+		stopHousekeeping()
+		done := make(chan struct{})
+		go func() {
+			app.wg.Wait()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+			shutdownError <- nil
+		case <-ctx.Done():
+			shutdownError <- errors.New("background tasks did not finish before the shutdown timeout")
+		}
+	}()
+
+	//staring the web server
+	app.logger.Info("starting server", "addr", srv.Addr, "env", app.config.env)
+	err := srv.ListenAndServe()
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	//ListenAndServe returns straight away once Shutdown is called so we wait for it to finish
+	err = <-shutdownError
+
+	var closeErr error
+	if db != nil {
+		app.logger.Info("closing database connection pool")
+		closeErr = db.Close()
+	}
+	if err != nil {
+		return err
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+
+	app.logger.Info("stopped server", "addr", srv.Addr)
+	return nil
+}
@@ -0,0 +1,44 @@
+// File: todoApi/backend/cmd/api/sqlite.go
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/url"
+	"time"
+
+	//a pure Go build of SQLite with FTS5 compiled in, so the default build needs no cgo
+	_ "modernc.org/sqlite"
+)
+
+// The openSQLite() function opens the sqlite database file, creating it when it does not exist yet
+func openSQLite(cfg config) (*sql.DB, error) {
+	//foreign keys are off by default in SQLite, the busy timeout makes writers wait for each other instead of failing
+	params := url.Values{}
+	params.Add("_pragma", "foreign_keys(1)")
+	params.Add("_pragma", "busy_timeout(5000)")
+	params.Add("_pragma", "journal_mode(WAL)")
+	params.Set("_txlock", "immediate")
+
+	db, err := sql.Open("sqlite", "file:"+cfg.sqlite.path+"?"+params.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	//task search relies on FTS5, checked here so a driver without it fails at startup rather than on the first search
+	var fts5 bool
+	err = db.QueryRowContext(ctx, `SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&fts5)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	if !fts5 {
+		db.Close()
+		return nil, errors.New("the sqlite driver was built without FTS5")
+	}
+	return db, nil
+}
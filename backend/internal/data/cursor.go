@@ -0,0 +1,379 @@
+// File: todoApi/backend/internal/data/cursor.go
+package data
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// orderKey is one column of the ORDER BY of a task listing
+type orderKey struct {
+	column string
+	desc   bool
+}
+
+// nullableColumns are the sortable columns that may hold NULL, which sorts after every value in ascending order
+var nullableColumns = map[string]bool{"due_at": true}
+
+// The orderKeys() method returns the complete ordering of a listing: the requested sort, then due_at and id
+// ending on id gives every task a unique position, which is what a cursor points at
+// the sort has to have passed ValidateFilter() as its columns are written into the SQL
+func (f Filters) orderKeys() []orderKey {
+	keys, err := f.sortKeys()
+	if err != nil {
+		panic("unsafe sort parameter: " + f.Sort)
+	}
+	for _, column := range []string{"due_at", "id"} {
+		if !containsColumn(keys, column) {
+			keys = append(keys, orderKey{column: column})
+		}
+	}
+	return keys
+}
+
+// containsColumn() reports whether one of the keys orders by column
+func containsColumn(keys []orderKey, column string) bool {
+	for _, key := range keys {
+		if key.column == column {
+			return true
+		}
+	}
+	return false
+}
+
+// reversed() flips every direction, a backward page is read in the reversed order and then put back the right way round
+func reversed(keys []orderKey) []orderKey {
+	flipped := make([]orderKey, len(keys))
+	for i, key := range keys {
+		flipped[i] = orderKey{column: key.column, desc: !key.desc}
+	}
+	return flipped
+}
+
+// orderBy() returns the ORDER BY list for keys, NULLs are placed explicitly so that reversing the keys reverses the rows
+func orderBy(keys []orderKey) string {
+	terms := make([]string, len(keys))
+	for i, key := range keys {
+		if key.desc {
+			terms[i] = key.column + " DESC NULLS FIRST"
+		} else {
+			terms[i] = key.column + " ASC NULLS LAST"
+		}
+	}
+	return strings.Join(terms, ", ")
+}
+
+// keysetCondition() returns the condition selecting the rows that come after values in the order of keys
+// bind() adds a value to the query's arguments and returns its placeholder
+func keysetCondition(keys []orderKey, values []interface{}, bind func(value interface{}) string) string {
+	var alternatives []string
+	var equal []string
+	for i, key := range keys {
+		value := values[i]
+
+		//NULL comes after every value in ascending order and before every value in descending order
+		var after string
+		switch {
+		case value == nil && key.desc:
+			after = key.column + " IS NOT NULL"
+		case value == nil:
+		case key.desc:
+			after = fmt.Sprintf("%s < %s", key.column, bind(value))
+		case nullableColumns[key.column]:
+			after = fmt.Sprintf("(%s > %s OR %s IS NULL)", key.column, bind(value), key.column)
+		default:
+			after = fmt.Sprintf("%s > %s", key.column, bind(value))
+		}
+		if after != "" {
+			alternatives = append(alternatives, "("+strings.Join(append(equal, after), " AND ")+")")
+		}
+
+		if value == nil {
+			equal = append(equal, key.column+" IS NULL")
+		} else {
+			equal = append(equal, fmt.Sprintf("%s = %s", key.column, bind(value)))
+		}
+	}
+	if len(alternatives) == 0 {
+		return "FALSE"
+	}
+	return "(" + strings.Join(alternatives, " OR ") + ")"
+}
+
+// columnValue() returns the value of a sortable or filterable column of the task, nil stands for NULL
+func columnValue(task *Task, column string) interface{} {
+	switch column {
+	case "id":
+		return task.ID
+	case "list_id":
+		if task.ListID == nil {
+			return nil
+		}
+		return *task.ListID
+	case "user_id":
+		if task.UserID == 0 {
+			return nil
+		}
+		return task.UserID
+	case "created_at":
+		return task.CreatedAt.UTC()
+	case "title":
+		return task.Title
+	case "description":
+		return task.Descritpion
+	case "completed":
+		return task.Completed
+	case "due_at":
+		if task.DueAt == nil {
+			return nil
+		}
+		return task.DueAt.UTC()
+	case "priority":
+		return int64(task.Priority)
+	}
+	panic("unknown task column: " + column)
+}
+
+// compareValues() orders two values of the same column, nil sorts after everything else
+func compareValues(a, b interface{}) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	}
+
+	switch a := a.(type) {
+	case int64:
+		return compareInt(a, b.(int64))
+	case string:
+		return strings.Compare(a, b.(string))
+	case bool:
+		switch {
+		case a == b.(bool):
+			return 0
+		case !a:
+			return -1
+		}
+		return 1
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	}
+	panic(fmt.Sprintf("cannot compare %T", a))
+}
+
+// compareTasks() orders two tasks by keys like the database would
+func compareTasks(a, b *Task, keys []orderKey) int {
+	for _, key := range keys {
+		c := compareValues(columnValue(a, key.column), columnValue(b, key.column))
+		if key.desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// afterCursor() reports whether the task comes after the cursor's values in the order of keys, the memory store's keysetCondition()
+func afterCursor(task *Task, keys []orderKey, values []interface{}) bool {
+	for i, key := range keys {
+		c := compareValues(columnValue(task, key.column), values[i])
+		if key.desc {
+			c = -c
+		}
+		if c != 0 {
+			return c > 0
+		}
+	}
+	return false
+}
+
+// Cursor points at a task in a sorted listing, the listing continues after it or, for Before, before it
+// it is handed to clients as an opaque token signed with the server's key so that it cannot be forged
+type Cursor struct {
+	Sort   string        `json:"s"` //the sort the cursor was issued for, a cursor is only valid with the same sort
+	Values []interface{} `json:"v"` //the task's values for each of the sort's orderKeys()
+	Before bool          `json:"b,omitempty"`
+}
+
+// newCursor() returns the cursor pointing at a task of the listing
+func (f Filters) newCursor(task *Task, before bool) *Cursor {
+	cursor := &Cursor{Sort: f.Sort, Before: before}
+	for _, key := range f.orderKeys() {
+		cursor.Values = append(cursor.Values, columnValue(task, key.column))
+	}
+	return cursor
+}
+
+// The Encode() method returns the cursor as a token: its JSON and a HMAC-SHA256 of it, both base64url encoded
+func (c *Cursor) Encode(key []byte) string {
+	payload, err := json.Marshal(c)
+	if err != nil {
+		panic(err)
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(payload)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// The decodeCursor() method checks the signature of f.Cursor and returns the cursor it holds
+// the values are converted back to the Go types of their columns, ErrInvalidCursor is returned for anything that does not fit
+func (f Filters) decodeCursor() (*Cursor, error) {
+	encodedPayload, encodedMAC, ok := strings.Cut(f.Cursor, ".")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedMAC)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	mac := hmac.New(sha256.New, f.CursorKey)
+	mac.Write(payload)
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, ErrInvalidCursor
+	}
+
+	var raw struct {
+		Sort   string            `json:"s"`
+		Values []json.RawMessage `json:"v"`
+		Before bool              `json:"b"`
+	}
+	if err := json.Unmarshal(payload, &raw); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	keys := f.orderKeys()
+	if raw.Sort != f.Sort || len(raw.Values) != len(keys) {
+		return nil, ErrInvalidCursor
+	}
+	cursor := &Cursor{Sort: raw.Sort, Before: raw.Before}
+	for i, key := range keys {
+		value, err := cursorValue(key.column, raw.Values[i])
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		cursor.Values = append(cursor.Values, value)
+	}
+	return cursor, nil
+}
+
+// cursorValue() decodes the JSON value of a column into the type returned by columnValue()
+func cursorValue(column string, raw json.RawMessage) (interface{}, error) {
+	if string(raw) == "null" {
+		if !nullableColumns[column] {
+			return nil, ErrInvalidCursor
+		}
+		return nil, nil
+	}
+
+	var err error
+	switch column {
+	case "id", "priority":
+		var n int64
+		err = json.Unmarshal(raw, &n)
+		return n, err
+	case "title", "description":
+		var s string
+		err = json.Unmarshal(raw, &s)
+		return s, err
+	case "completed":
+		var b bool
+		err = json.Unmarshal(raw, &b)
+		return b, err
+	case "due_at", "created_at":
+		var t time.Time
+		err = json.Unmarshal(raw, &t)
+		return t.UTC(), err
+	}
+	return nil, ErrInvalidCursor
+}
+
+// pageQuery describes how a listing is read for the filters
+type pageQuery struct {
+	keys   []orderKey //the ORDER BY, reversed for a backward page
+	cursor *Cursor    //nil in page mode
+	limit  int        //one more than the page size to find out whether there is another page
+	offset int        //always 0 when a cursor is used
+}
+
+// The pageQuery() method works out the ordering, limit and offset of a listing
+// the cursor has already been checked by ValidateFilter() so a token that no longer decodes is an error
+func (f Filters) pageQuery() (pageQuery, error) {
+	q := pageQuery{keys: f.orderKeys(), limit: f.limit() + 1, offset: f.offSet()}
+	if f.Cursor == "" {
+		return q, nil
+	}
+
+	cursor, err := f.decodeCursor()
+	if err != nil {
+		return q, err
+	}
+	q.cursor, q.offset = cursor, 0
+	if cursor.Before {
+		q.keys = reversed(q.keys)
+	}
+	return q, nil
+}
+
+// The finishPage() method turns the rows read for q into the page and fills in the metadata
+// the look-ahead row is dropped, a backward page is put back in order and the cursors of the neighbouring pages are set
+// total is nil when the count was not asked for
+func (f Filters) finishPage(q pageQuery, tasks []*Task, total *int) ([]*Task, Metadata) {
+	more := len(tasks) > f.limit()
+	if more {
+		tasks = tasks[:f.limit()]
+	}
+	backward := q.cursor != nil && q.cursor.Before
+	if backward {
+		for i, j := 0, len(tasks)-1; i < j; i, j = i+1, j-1 {
+			tasks[i], tasks[j] = tasks[j], tasks[i]
+		}
+	}
+
+	var metadata Metadata
+	switch {
+	case total != nil && q.cursor == nil:
+		metadata = calculateMetaData(*total, f.Page, f.PageSize)
+	case total != nil:
+		metadata = Metadata{PageSize: f.PageSize, TotalRecords: *total}
+	case q.cursor == nil:
+		metadata = Metadata{CurrentPage: f.Page, PageSize: f.PageSize}
+	default:
+		metadata = Metadata{PageSize: f.PageSize}
+	}
+	if len(tasks) == 0 {
+		return tasks, metadata
+	}
+
+	//reading forward there is more after the page when the look-ahead row was found, and there is something before
+	//it when it was reached through a cursor or a later page, reading backward it is the other way round
+	hasNext, hasPrev := more, q.cursor != nil || f.Page > 1
+	if backward {
+		hasNext, hasPrev = true, more
+	}
+	if hasNext {
+		metadata.NextCursor = f.newCursor(tasks[len(tasks)-1], false).Encode(f.CursorKey)
+	}
+	if hasPrev {
+		metadata.PrevCursor = f.newCursor(tasks[0], true).Encode(f.CursorKey)
+	}
+	return tasks, metadata
+}
@@ -2,6 +2,7 @@
 package data
 
 import (
+	"fmt"
 	"math"
 	"strings"
 
@@ -9,10 +10,13 @@ import (
 )
 
 type Filters struct {
-	Page     int
-	PageSize int
-	Sort     string
-	SortList []string
+	Page         int
+	PageSize     int
+	Sort         string   //comma separated columns, ascending or descending with a leading "-" e.g. -completed,title
+	SortColumns  []string //the columns the listing can be sorted by, e.g. TaskSortColumns
+	Cursor       string   //a token from next_cursor or prev_cursor, it replaces Page
+	CursorKey    []byte   //signs and checks the cursor tokens
+	IncludeTotal bool     //counting every match, and the tag counts, costs a second pass over them
 }
 
 func ValidateFilter(v *validator.Validator, f Filters) {
@@ -22,26 +26,37 @@ func ValidateFilter(v *validator.Validator, f Filters) {
 	v.Check(f.PageSize > 0, "page_size", "must be greater than zero")
 	v.Check(f.PageSize <= 100, "page_size", "maximum of 100")
 
-	//checking that the sort parameter matches the value in the acceptable sort list
-	v.Check(validator.In(f.Sort, f.SortList...), "sort", "invalid sort value")
-}
+	//checking that every key of the sort parameter is a sortable column
+	_, err := f.sortKeys()
+	if err != nil {
+		v.AddError("sort", err.Error())
+	}
 
-// The sortColumn() method safely extracts the sort field query parameter
-func (f Filters) sortColumn() string {
-	for _, safeValue := range f.SortList {
-		if f.Sort == safeValue {
-			return strings.TrimPrefix(f.Sort, "-")
-		}
+	//a cursor carries its own position so it cannot be mixed with a page number
+	if f.Cursor != "" && err == nil {
+		v.Check(f.Page == 1, "page", "must not be used with cursor")
+		_, err := f.decodeCursor()
+		v.Check(err == nil, "cursor", "must be a next_cursor or prev_cursor returned for the same sort")
 	}
-	panic("unsafe sort parameter: " + f.Sort)
 }
 
-// The sortOrder() method determins wheter we should sort by DESC/ASC
-func (f Filters) sortOrder() string {
-	if strings.HasPrefix(f.Sort, "-") {
-		return "DESC"
+// The sortKeys() method splits the sort parameter into its keys
+// an error names the first key that is not one of the sortable columns or that repeats a column
+func (f Filters) sortKeys() ([]orderKey, error) {
+	var keys []orderKey
+	seen := make(map[string]bool)
+	for _, key := range strings.Split(f.Sort, ",") {
+		column := strings.TrimPrefix(key, "-")
+		switch {
+		case !validator.In(column, f.SortColumns...):
+			return nil, fmt.Errorf("%q is not a sortable field, must be one of %s with an optional leading -", key, strings.Join(f.SortColumns, ", "))
+		case seen[column]:
+			return nil, fmt.Errorf("%q sorts by %s more than once", key, column)
+		}
+		seen[column] = true
+		keys = append(keys, orderKey{column: column, desc: strings.HasPrefix(key, "-")})
 	}
-	return "ASC"
+	return keys, nil
 }
 
 // The limit() method detemerins the LIMIT
@@ -56,11 +71,14 @@ func (f Filters) offSet() int {
 
 // The metadata type contains metadata to help with pagination
 type Metadata struct {
-	CurrentPage  int `json:"current_page,omitempty"`
-	PageSize     int `json:"page_size,omitempty"`
-	FirstPage    int `json:"first_page,omitempty"`
-	LastPage     int `json:"last_page,omitempty"`
-	TotalRecords int `json:"total_records,omitempty"`
+	CurrentPage  int            `json:"current_page,omitempty"`
+	PageSize     int            `json:"page_size,omitempty"`
+	FirstPage    int            `json:"first_page,omitempty"`
+	LastPage     int            `json:"last_page,omitempty"`
+	TotalRecords int            `json:"total_records,omitempty"`
+	NextCursor   string         `json:"next_cursor,omitempty"`
+	PrevCursor   string         `json:"prev_cursor,omitempty"`
+	TagCounts    map[string]int `json:"tag_counts,omitempty"`
 }
 
 // The calculateMetaData() function computes the values for the metadata fields
@@ -0,0 +1,53 @@
+// File: todoApi/backend/internal/data/health.go
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// MigrationStatus is the schema version recorded by the migration tool
+// Dirty is set when a migration failed part way through and has to be fixed by hand
+type MigrationStatus struct {
+	Version int64 `json:"version"`
+	Dirty   bool  `json:"dirty"`
+}
+
+type HealthModel struct {
+	DB *sql.DB
+}
+
+// Ping() checks that a database connection can be used before ctx is done
+func (m HealthModel) Ping(ctx context.Context) error {
+	return m.DB.PingContext(ctx)
+}
+
+// MigrationVersion() returns the applied schema version from the schema_migrations table
+// ErrRecordNotFound is returned when no migration has been applied yet
+func (m HealthModel) MigrationVersion(ctx context.Context) (*MigrationStatus, error) {
+	query := `
+		SELECT version, dirty
+		FROM schema_migrations
+		ORDER BY version DESC
+		LIMIT 1
+	`
+
+	var status MigrationStatus
+	err := m.DB.QueryRowContext(ctx, query).Scan(&status.Version, &status.Dirty)
+	if err != nil {
+		//42P01 is undefined_table, the migrations have never been run
+		var pqErr *pq.Error
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		case errors.As(err, &pqErr) && pqErr.Code == "42P01":
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &status, nil
+}
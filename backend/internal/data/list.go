@@ -0,0 +1,238 @@
+// File: todoApi/backend/internal/data/list.go
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"todo.michaelgomez.net/internal/validator"
+)
+
+var (
+	ErrListNotEmpty = errors.New("list not empty")
+)
+
+// list struct supports the information for a named todo list (project) that owns tasks
+// like a task it belongs to the user that created it, see TaskModel for how the userID arguments scope the queries
+type List struct {
+	ID          int64     `json:"id"`
+	UserID      int64     `json:"-"`
+	CreatedAt   time.Time `json:"-"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	TaskCount   int       `json:"task_count"`
+	Version     int32     `json:"version"`
+}
+
+func ValidateList(v *validator.Validator, list *List) {
+	v.Check(list.Name != "", "name", "must be provided")
+	v.Check(len(list.Name) <= 100, "name", "must not be more than 100 bytes long")
+
+	v.Check(len(list.Description) <= 250, "description", "must not be more than 250 bytes long")
+}
+
+type ListModel struct {
+	DB      *sql.DB
+	Timeout time.Duration //the longest a single query may run
+}
+
+// Insert() allows us to create a new list
+func (m ListModel) Insert(ctx context.Context, list *List) error {
+	query := `
+		INSERT INTO lists (user_id, name, description)
+		VALUES (NULLIF($1::bigint, 0), $2, $3)
+		RETURNING id, created_at, version
+	`
+
+	//creating the context
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	//Cleaning up to prevent memory leaks
+	defer cancel()
+
+	args := []interface{}{list.UserID, list.Name, list.Description}
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&list.ID, &list.CreatedAt, &list.Version)
+}
+
+// Get() allows us to retrieve a specific list owned by the user
+func (m ListModel) Get(ctx context.Context, id int64, userID int64) (*List, error) {
+	//Ensure that there is a valid id
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, COALESCE(user_id, 0), created_at, name, description,
+		(SELECT COUNT(*) FROM task_list WHERE list_id = lists.id),
+		version
+		FROM lists
+		WHERE id = $1
+		AND ($2::bigint = -1 OR user_id IS NOT DISTINCT FROM NULLIF($2::bigint, 0))
+	`
+
+	var list List
+
+	//Creating the context
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	//Cleaning up to prevent memory leaks
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id, userID).Scan(
+		&list.ID,
+		&list.UserID,
+		&list.CreatedAt,
+		&list.Name,
+		&list.Description,
+		&list.TaskCount,
+		&list.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &list, nil
+}
+
+// Update() allows us to edit a specific list, optimistic locking (version number)
+func (m ListModel) Update(ctx context.Context, list *List) error {
+	query := `
+		UPDATE lists
+		SET name = $1, description = $2, version = version + 1
+		WHERE id = $3
+		AND version = $4
+		AND user_id IS NOT DISTINCT FROM NULLIF($5::bigint, 0)
+		RETURNING version
+	`
+	args := []interface{}{list.Name, list.Description, list.ID, list.Version, list.UserID}
+
+	//Creating the context
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	//Cleaning up to prevent memory leaks
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&list.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete() removes a specific list owned by the user
+// a list that still holds tasks is kept and ErrListNotEmpty is returned, unless cascade is set in which case its tasks are deleted with it
+func (m ListModel) Delete(ctx context.Context, id int64, userID int64, cascade bool) error {
+	//Ensure that there is a valid id
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	//creating the context
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	//clearing up to prevent memory leaks
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	//locking the list row so no task can be moved into it while we check
+	var exists bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT TRUE FROM lists
+		WHERE id = $1
+		AND ($2::bigint = -1 OR user_id IS NOT DISTINCT FROM NULLIF($2::bigint, 0))
+		FOR UPDATE
+	`, id, userID).Scan(&exists)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	owned := `list_id = $1 AND ($2::bigint = -1 OR user_id IS NOT DISTINCT FROM NULLIF($2::bigint, 0))`
+	if cascade {
+		_, err = tx.ExecContext(ctx, `DELETE FROM task_list WHERE `+owned, id, userID)
+		if err != nil {
+			return err
+		}
+	} else {
+		var taskCount int
+		err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM task_list WHERE `+owned, id, userID).Scan(&taskCount)
+		if err != nil {
+			return err
+		}
+		if taskCount > 0 {
+			return ErrListNotEmpty
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE task_list SET list_id = NULL, version = version + 1 WHERE list_id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM lists WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// the GetAll() method returns the user's lists sorted by name along with how many tasks each owns
+func (m ListModel) GetAll(ctx context.Context, userID int64) ([]*List, error) {
+	query := `
+		SELECT id, COALESCE(user_id, 0), created_at, name, description,
+		(SELECT COUNT(*) FROM task_list WHERE list_id = lists.id),
+		version
+		FROM lists
+		WHERE ($1::bigint = -1 OR user_id IS NOT DISTINCT FROM NULLIF($1::bigint, 0))
+		ORDER BY name ASC, id ASC
+	`
+
+	//creating the time out context
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lists := []*List{}
+	for rows.Next() {
+		var list List
+		err := rows.Scan(
+			&list.ID,
+			&list.UserID,
+			&list.CreatedAt,
+			&list.Name,
+			&list.Description,
+			&list.TaskCount,
+			&list.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+		lists = append(lists, &list)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return lists, nil
+}
@@ -0,0 +1,541 @@
+// File: todoApi/backend/internal/data/memory.go
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memoryDB keeps every record in maps guarded by a single lock
+// it mirrors the PostgreSQL schema so the memory stores behave like the models in front of the real database
+// records are copied on the way in and out so callers can never change the stored state without an Insert or Update
+// nothing the memory stores do blocks so the contexts they are given are never checked
+type memoryDB struct {
+	mu sync.RWMutex
+
+	lastID map[string]int64 //the bigserial sequence of each table
+
+	tasks       map[int64]*Task          //tags are kept in taskTags rather than on the task
+	taskTags    map[int64]map[int64]bool //task id to the set of its tag ids
+	tags        map[int64]*Tag           //TaskCount is computed when a tag is read
+	lists       map[int64]*List          //TaskCount is computed when a list is read
+	users       map[int64]*User          //keyed by id
+	tokens      map[[sha256.Size]byte]Token
+	permissions map[int64]map[string]bool //user id to the set of granted codes
+}
+
+// permissionCodes are the codes seeded by the add_permissions migration
+var permissionCodes = []string{PermissionTodoRead, PermissionTodoWrite, PermissionTodoAdmin}
+
+// NewMemoryModels() returns models that keep everything in memory, nothing survives a restart
+// it lets the API run for demos and tests without a database
+func NewMemoryModels() Models {
+	db := &memoryDB{
+		lastID:      make(map[string]int64),
+		tasks:       make(map[int64]*Task),
+		taskTags:    make(map[int64]map[int64]bool),
+		tags:        make(map[int64]*Tag),
+		lists:       make(map[int64]*List),
+		users:       make(map[int64]*User),
+		tokens:      make(map[[sha256.Size]byte]Token),
+		permissions: make(map[int64]map[string]bool),
+	}
+
+	return Models{
+		Tasks:       MemoryTaskStore{db: db},
+		Tags:        MemoryTagStore{db: db},
+		Lists:       MemoryListStore{db: db},
+		Users:       MemoryUserStore{db: db},
+		Tokens:      MemoryTokenStore{db: db},
+		Permissions: MemoryPermissionStore{db: db},
+		Health:      MemoryHealthStore{},
+	}
+}
+
+// nextID() returns the next value of a table's sequence, the caller holds the write lock
+func (db *memoryDB) nextID(table string) int64 {
+	db.lastID[table]++
+	return db.lastID[table]
+}
+
+// visibleTo() reports whether a record owned by ownerID is visible to userID, AnyUser sees every record
+func visibleTo(ownerID, userID int64) bool {
+	return userID == AnyUser || ownerID == userID
+}
+
+// now() returns the current time at the precision of a timestamp(0) column
+func now() time.Time {
+	return time.Now().Truncate(time.Second)
+}
+
+// tagNames() returns the sorted names of a task's tags, the caller holds the lock
+func (db *memoryDB) tagNames(taskID int64) []string {
+	names := []string{}
+	for tagID := range db.taskTags[taskID] {
+		names = append(names, db.tags[tagID].Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// tagByName() returns the user's tag with the name or nil, the caller holds the lock
+func (db *memoryDB) tagByName(userID int64, name string) *Tag {
+	for _, tag := range db.tags {
+		if tag.UserID == userID && tag.Name == name {
+			return tag
+		}
+	}
+	return nil
+}
+
+// setTaskTags() replaces the tags attached to a task with its owner's tags, creating the ones that do not exist yet
+// the caller holds the write lock
+func (db *memoryDB) setTaskTags(taskID int64, userID int64, names []string) {
+	set := make(map[int64]bool, len(names))
+	for _, name := range names {
+		tag := db.tagByName(userID, name)
+		if tag == nil {
+			tag = &Tag{ID: db.nextID("tags"), UserID: userID, CreatedAt: now(), Name: name, Version: 1}
+			db.tags[tag.ID] = tag
+		}
+		set[tag.ID] = true
+	}
+	db.taskTags[taskID] = set
+}
+
+// deleteTask() removes a task together with its subtasks at any depth, the caller holds the write lock
+func (db *memoryDB) deleteTask(id int64) {
+	delete(db.tasks, id)
+	delete(db.taskTags, id)
+	for childID, child := range db.tasks {
+		if child.ParentID != nil && *child.ParentID == id {
+			db.deleteTask(childID)
+		}
+	}
+}
+
+type MemoryTagStore struct {
+	db *memoryDB
+}
+
+// Insert() allows us to create a new tag
+func (m MemoryTagStore) Insert(ctx context.Context, tag *Tag) error {
+	m.db.mu.Lock()
+	defer m.db.mu.Unlock()
+
+	if m.db.tagByName(tag.UserID, tag.Name) != nil {
+		return ErrDuplicateTag
+	}
+
+	stored := Tag{ID: m.db.nextID("tags"), UserID: tag.UserID, CreatedAt: now(), Name: tag.Name, Version: 1}
+	m.db.tags[stored.ID] = &stored
+
+	tag.ID, tag.CreatedAt, tag.Version = stored.ID, stored.CreatedAt, stored.Version
+	return nil
+}
+
+// Get() allows us to retrieve a specific tag owned by the user
+func (m MemoryTagStore) Get(ctx context.Context, id int64, userID int64) (*Tag, error) {
+	m.db.mu.RLock()
+	defer m.db.mu.RUnlock()
+
+	stored, ok := m.db.tags[id]
+	if !ok || !visibleTo(stored.UserID, userID) {
+		return nil, ErrRecordNotFound
+	}
+	return m.withTaskCount(stored), nil
+}
+
+// Update() renames a tag, optimistic locking (version number)
+func (m MemoryTagStore) Update(ctx context.Context, tag *Tag) error {
+	m.db.mu.Lock()
+	defer m.db.mu.Unlock()
+
+	stored, ok := m.db.tags[tag.ID]
+	if !ok || stored.Version != tag.Version || stored.UserID != tag.UserID {
+		return ErrEditConflict
+	}
+	if other := m.db.tagByName(tag.UserID, tag.Name); other != nil && other.ID != tag.ID {
+		return ErrDuplicateTag
+	}
+
+	stored.Name = tag.Name
+	stored.Version++
+	tag.Version = stored.Version
+	return nil
+}
+
+// Delete() removes a specific tag owned by the user, it is detached from every task that carried it
+func (m MemoryTagStore) Delete(ctx context.Context, id int64, userID int64) error {
+	m.db.mu.Lock()
+	defer m.db.mu.Unlock()
+
+	if stored, ok := m.db.tags[id]; !ok || !visibleTo(stored.UserID, userID) {
+		return ErrRecordNotFound
+	}
+	delete(m.db.tags, id)
+	for _, set := range m.db.taskTags {
+		delete(set, id)
+	}
+	return nil
+}
+
+// the GetAll() method returns the user's tags sorted by name along with how many tasks use each
+func (m MemoryTagStore) GetAll(ctx context.Context, userID int64) ([]*Tag, error) {
+	m.db.mu.RLock()
+	defer m.db.mu.RUnlock()
+
+	tags := []*Tag{}
+	for _, stored := range m.db.tags {
+		if visibleTo(stored.UserID, userID) {
+			tags = append(tags, m.withTaskCount(stored))
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Name != tags[j].Name {
+			return tags[i].Name < tags[j].Name
+		}
+		return tags[i].ID < tags[j].ID
+	})
+	return tags, nil
+}
+
+// withTaskCount() returns a copy of the tag with the number of tasks carrying it, the caller holds the lock
+func (m MemoryTagStore) withTaskCount(stored *Tag) *Tag {
+	tag := *stored
+	for _, set := range m.db.taskTags {
+		if set[tag.ID] {
+			tag.TaskCount++
+		}
+	}
+	return &tag
+}
+
+type MemoryListStore struct {
+	db *memoryDB
+}
+
+// Insert() allows us to create a new list
+func (m MemoryListStore) Insert(ctx context.Context, list *List) error {
+	m.db.mu.Lock()
+	defer m.db.mu.Unlock()
+
+	stored := List{ID: m.db.nextID("lists"), UserID: list.UserID, CreatedAt: now(), Name: list.Name, Description: list.Description, Version: 1}
+	m.db.lists[stored.ID] = &stored
+
+	list.ID, list.CreatedAt, list.Version = stored.ID, stored.CreatedAt, stored.Version
+	return nil
+}
+
+// Get() allows us to retrieve a specific list owned by the user
+func (m MemoryListStore) Get(ctx context.Context, id int64, userID int64) (*List, error) {
+	m.db.mu.RLock()
+	defer m.db.mu.RUnlock()
+
+	stored, ok := m.db.lists[id]
+	if !ok || !visibleTo(stored.UserID, userID) {
+		return nil, ErrRecordNotFound
+	}
+	return m.withTaskCount(stored), nil
+}
+
+// Update() allows us to edit a specific list, optimistic locking (version number)
+func (m MemoryListStore) Update(ctx context.Context, list *List) error {
+	m.db.mu.Lock()
+	defer m.db.mu.Unlock()
+
+	stored, ok := m.db.lists[list.ID]
+	if !ok || stored.Version != list.Version || stored.UserID != list.UserID {
+		return ErrEditConflict
+	}
+
+	stored.Name, stored.Description = list.Name, list.Description
+	stored.Version++
+	list.Version = stored.Version
+	return nil
+}
+
+// Delete() removes a specific list owned by the user, see ListModel.Delete()
+func (m MemoryListStore) Delete(ctx context.Context, id int64, userID int64, cascade bool) error {
+	m.db.mu.Lock()
+	defer m.db.mu.Unlock()
+
+	if stored, ok := m.db.lists[id]; !ok || !visibleTo(stored.UserID, userID) {
+		return ErrRecordNotFound
+	}
+
+	inList := func(task *Task) bool {
+		return task.ListID != nil && *task.ListID == id
+	}
+	for _, task := range m.db.tasks {
+		if !cascade && inList(task) && visibleTo(task.UserID, userID) {
+			return ErrListNotEmpty
+		}
+	}
+	for taskID, task := range m.db.tasks {
+		switch {
+		case !inList(task):
+		case visibleTo(task.UserID, userID):
+			m.db.deleteTask(taskID)
+		default:
+			task.ListID = nil
+			task.Version++
+		}
+	}
+
+	delete(m.db.lists, id)
+	return nil
+}
+
+// the GetAll() method returns the user's lists sorted by name along with how many tasks each owns
+func (m MemoryListStore) GetAll(ctx context.Context, userID int64) ([]*List, error) {
+	m.db.mu.RLock()
+	defer m.db.mu.RUnlock()
+
+	lists := []*List{}
+	for _, stored := range m.db.lists {
+		if visibleTo(stored.UserID, userID) {
+			lists = append(lists, m.withTaskCount(stored))
+		}
+	}
+	sort.Slice(lists, func(i, j int) bool {
+		if lists[i].Name != lists[j].Name {
+			return lists[i].Name < lists[j].Name
+		}
+		return lists[i].ID < lists[j].ID
+	})
+	return lists, nil
+}
+
+// withTaskCount() returns a copy of the list with the number of tasks it owns, the caller holds the lock
+func (m MemoryListStore) withTaskCount(stored *List) *List {
+	list := *stored
+	for _, task := range m.db.tasks {
+		if task.ListID != nil && *task.ListID == list.ID {
+			list.TaskCount++
+		}
+	}
+	return &list
+}
+
+type MemoryUserStore struct {
+	db *memoryDB
+}
+
+// Insert() allows us to create a new user
+func (m MemoryUserStore) Insert(ctx context.Context, user *User) error {
+	m.db.mu.Lock()
+	defer m.db.mu.Unlock()
+
+	return m.insert(user)
+}
+
+// Register() creates a new user together with their permissions and an activation token
+// the write lock is held throughout so nobody sees the account before it is complete
+func (m MemoryUserStore) Register(ctx context.Context, user *User, codes []string, activationTTL time.Duration) (*Token, error) {
+	//the token is made first as it is the only step that can fail once the user is stored
+	token, err := generateToken(0, activationTTL, ScopeActivation)
+	if err != nil {
+		return nil, err
+	}
+
+	m.db.mu.Lock()
+	defer m.db.mu.Unlock()
+
+	err = m.insert(user)
+	if err != nil {
+		return nil, err
+	}
+	m.db.addPermissions(user.ID, codes)
+	token.UserID = user.ID
+	m.db.insertToken(token)
+	return token, nil
+}
+
+// insert() stores a new user, the caller holds the write lock
+func (m MemoryUserStore) insert(user *User) error {
+	if m.byEmail(user.Email) != nil {
+		return ErrDuplicateEmail
+	}
+
+	stored := *user
+	stored.ID = m.db.nextID("users")
+	stored.CreatedAt = now()
+	stored.Version = 1
+	stored.Password.plaintext = nil
+	m.db.users[stored.ID] = &stored
+
+	user.ID, user.CreatedAt, user.Version = stored.ID, stored.CreatedAt, stored.Version
+	return nil
+}
+
+// GetByEmail() allows us to retrieve a user by their email address
+func (m MemoryUserStore) GetByEmail(ctx context.Context, email string) (*User, error) {
+	m.db.mu.RLock()
+	defer m.db.mu.RUnlock()
+
+	stored := m.byEmail(email)
+	if stored == nil {
+		return nil, ErrRecordNotFound
+	}
+	user := *stored
+	return &user, nil
+}
+
+// Update() allows us to edit a user, optimistic locking (version number)
+func (m MemoryUserStore) Update(ctx context.Context, user *User) error {
+	m.db.mu.Lock()
+	defer m.db.mu.Unlock()
+
+	if other := m.byEmail(user.Email); other != nil && other.ID != user.ID {
+		return ErrDuplicateEmail
+	}
+	stored, ok := m.db.users[user.ID]
+	if !ok || stored.Version != user.Version {
+		return ErrEditConflict
+	}
+
+	version := stored.Version + 1
+	*stored = *user
+	stored.Version = version
+	stored.Password.plaintext = nil
+	user.Version = version
+	return nil
+}
+
+// GetForToken() retrieves the user that owns an unexpired token of the given scope
+func (m MemoryUserStore) GetForToken(ctx context.Context, tokenScope, tokenPlaintext string) (*User, error) {
+	m.db.mu.RLock()
+	defer m.db.mu.RUnlock()
+
+	token, ok := m.db.tokens[sha256.Sum256([]byte(tokenPlaintext))]
+	if !ok || token.Scope != tokenScope || !token.Expiry.After(time.Now()) {
+		return nil, ErrRecordNotFound
+	}
+	stored, ok := m.db.users[token.UserID]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+	user := *stored
+	return &user, nil
+}
+
+// byEmail() finds a user ignoring case like the citext column does, the caller holds the lock
+func (m MemoryUserStore) byEmail(email string) *User {
+	for _, user := range m.db.users {
+		if strings.EqualFold(user.Email, email) {
+			return user
+		}
+	}
+	return nil
+}
+
+type MemoryTokenStore struct {
+	db *memoryDB
+}
+
+// New() generates a token for the user and stores its hash
+func (m MemoryTokenStore) New(ctx context.Context, userID int64, ttl time.Duration, scope string) (*Token, error) {
+	token, err := generateToken(userID, ttl, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.Insert(ctx, token)
+	return token, err
+}
+
+// Insert() stores a token
+func (m MemoryTokenStore) Insert(ctx context.Context, token *Token) error {
+	if len(token.Hash) != sha256.Size {
+		return errors.New("invalid token hash")
+	}
+
+	m.db.mu.Lock()
+	defer m.db.mu.Unlock()
+
+	m.db.insertToken(token)
+	return nil
+}
+
+// insertToken() stores a token whose hash has the length of a SHA-256 sum, the caller holds the write lock
+func (db *memoryDB) insertToken(token *Token) {
+	var hash [sha256.Size]byte
+	copy(hash[:], token.Hash)
+
+	stored := *token
+	stored.Plaintext = ""
+	db.tokens[hash] = stored
+}
+
+// DeleteAllForUser() removes every token of a scope that belongs to the user
+func (m MemoryTokenStore) DeleteAllForUser(ctx context.Context, scope string, userID int64) error {
+	m.db.mu.Lock()
+	defer m.db.mu.Unlock()
+
+	for hash, token := range m.db.tokens {
+		if token.Scope == scope && token.UserID == userID {
+			delete(m.db.tokens, hash)
+		}
+	}
+	return nil
+}
+
+type MemoryPermissionStore struct {
+	db *memoryDB
+}
+
+// GetAllForUser() returns every permission code granted to the user
+func (m MemoryPermissionStore) GetAllForUser(ctx context.Context, userID int64) (Permissions, error) {
+	m.db.mu.RLock()
+	defer m.db.mu.RUnlock()
+
+	var permissions Permissions
+	for _, code := range permissionCodes {
+		if m.db.permissions[userID][code] {
+			permissions = append(permissions, code)
+		}
+	}
+	return permissions, nil
+}
+
+// AddForUser() grants the permission codes to the user, unknown codes are ignored
+func (m MemoryPermissionStore) AddForUser(ctx context.Context, userID int64, codes ...string) error {
+	m.db.mu.Lock()
+	defer m.db.mu.Unlock()
+
+	m.db.addPermissions(userID, codes)
+	return nil
+}
+
+// addPermissions() grants the known codes among codes to the user, the caller holds the write lock
+func (db *memoryDB) addPermissions(userID int64, codes []string) {
+	if db.permissions[userID] == nil {
+		db.permissions[userID] = make(map[string]bool)
+	}
+	for _, code := range codes {
+		for _, known := range permissionCodes {
+			if code == known {
+				db.permissions[userID][code] = true
+			}
+		}
+	}
+}
+
+// MemoryHealthStore is always ready, there is no connection to lose and no schema to migrate
+type MemoryHealthStore struct{}
+
+// Ping() never fails
+func (MemoryHealthStore) Ping(ctx context.Context) error {
+	return nil
+}
+
+// MigrationVersion() reports version 0 since nothing is ever migrated
+func (MemoryHealthStore) MigrationVersion(ctx context.Context) (*MigrationStatus, error) {
+	return &MigrationStatus{}, nil
+}
@@ -0,0 +1,441 @@
+// File: todoApi/backend/internal/data/memory_tasks.go
+package data
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// MemoryTaskStore keeps tasks in memory, it follows the same ownership rules as TaskModel
+type MemoryTaskStore struct {
+	db *memoryDB
+}
+
+// copyTask() returns a deep copy of a stored task with its tags filled in, the caller holds the lock
+func (m MemoryTaskStore) copyTask(stored *Task) *Task {
+	task := *stored
+	if stored.ListID != nil {
+		listID := *stored.ListID
+		task.ListID = &listID
+	}
+	if stored.ParentID != nil {
+		parentID := *stored.ParentID
+		task.ParentID = &parentID
+	}
+	if stored.DueAt != nil {
+		dueAt := *stored.DueAt
+		task.DueAt = &dueAt
+	}
+	if stored.RemindAt != nil {
+		remindAt := *stored.RemindAt
+		task.RemindAt = &remindAt
+	}
+	task.Tags = m.db.tagNames(stored.ID)
+	task.Subtasks = nil
+	return &task
+}
+
+// owns() reports whether a task is visible to userID, AnyUser sees every task
+func owns(task *Task, userID int64) bool {
+	return visibleTo(task.UserID, userID)
+}
+
+// checkReferences() stands in for the foreign keys on list_id and parent_id, the caller holds the lock
+func (m MemoryTaskStore) checkReferences(task *Task) error {
+	if task.ListID != nil {
+		if _, ok := m.db.lists[*task.ListID]; !ok {
+			return fmt.Errorf("list %d does not exist", *task.ListID)
+		}
+	}
+	if task.ParentID != nil {
+		if _, ok := m.db.tasks[*task.ParentID]; !ok {
+			return fmt.Errorf("parent task %d does not exist", *task.ParentID)
+		}
+	}
+	return nil
+}
+
+// Insert() allows us to create a new task
+func (m MemoryTaskStore) Insert(ctx context.Context, task *Task) error {
+	m.db.mu.Lock()
+	defer m.db.mu.Unlock()
+
+	if err := m.checkReferences(task); err != nil {
+		return err
+	}
+
+	task.Tags = sortedTags(task.Tags)
+	task.ID = m.db.nextID("task_list")
+	task.CreatedAt = now()
+	task.Version = 1
+
+	m.db.tasks[task.ID] = m.copyTask(task)
+	m.db.setTaskTags(task.ID, task.UserID, task.Tags)
+	return nil
+}
+
+// Get() allows us to retrieve a specific task owned by the user
+func (m MemoryTaskStore) Get(ctx context.Context, id int64, userID int64) (*Task, error) {
+	m.db.mu.RLock()
+	defer m.db.mu.RUnlock()
+
+	stored, ok := m.db.tasks[id]
+	if !ok || !owns(stored, userID) {
+		return nil, ErrRecordNotFound
+	}
+	return m.copyTask(stored), nil
+}
+
+// Update() allows us to edit/alter a specific task
+// Optimistic locking (version number)
+// when the update completes the task its incomplete subtasks are handled as completion says, under the same lock
+// a move under another parent is checked under the lock too, see TaskModel.Update()
+func (m MemoryTaskStore) Update(ctx context.Context, task *Task, completion SubtaskCompletion) error {
+	m.db.mu.Lock()
+	defer m.db.mu.Unlock()
+
+	stored, ok := m.db.tasks[task.ID]
+	if !ok || stored.Version != task.Version || stored.UserID != task.UserID {
+		return ErrEditConflict
+	}
+	if err := m.checkReferences(task); err != nil {
+		return err
+	}
+	if task.moving(stored.ParentID) {
+		ancestors, err := m.ancestors(*task.ParentID)
+		if err != nil {
+			return err
+		}
+		if err := parentError(task, ancestors, m.subtreeHeight(task.ID)); err != nil {
+			return err
+		}
+	}
+
+	completing := task.Completed && !stored.Completed
+	if completing && completion == SubtasksBlock {
+		for _, subtask := range m.descendants(task.ID) {
+			if !subtask.Completed {
+				return ErrIncompleteSubtasks
+			}
+		}
+	}
+
+	task.Tags = sortedTags(task.Tags)
+	task.Version++
+
+	updated := m.copyTask(task)
+	updated.CreatedAt = stored.CreatedAt
+	m.db.tasks[task.ID] = updated
+	m.db.setTaskTags(task.ID, task.UserID, task.Tags)
+
+	if completing && completion == SubtasksCascade {
+		for _, subtask := range m.descendants(task.ID) {
+			if !subtask.Completed {
+				subtask.Completed = true
+				subtask.Version++
+			}
+		}
+	}
+	return nil
+}
+
+// Delete() removes a specific task owned by the user, its subtasks go with it
+func (m MemoryTaskStore) Delete(ctx context.Context, id int64, userID int64) error {
+	m.db.mu.Lock()
+	defer m.db.mu.Unlock()
+
+	stored, ok := m.db.tasks[id]
+	if !ok || !owns(stored, userID) {
+		return ErrRecordNotFound
+	}
+	m.db.deleteTask(id)
+	return nil
+}
+
+// the GetAll() method filters, sorts and pages the user's tasks exactly like TaskModel.GetAll()
+func (m MemoryTaskStore) GetAll(ctx context.Context, userID int64, filter TaskFilter, filters Filters) ([]*Task, Metadata, error) {
+	m.db.mu.RLock()
+	defer m.db.mu.RUnlock()
+
+	filter = append(TaskFilter{ownedBy(userID)}, filter...)
+	matches := []*Task{}
+	for _, stored := range m.db.tasks {
+		task := m.copyTask(stored)
+		if filter.match(task) {
+			matches = append(matches, task)
+		}
+	}
+
+	//the page is cut out of the sorted matches, either after the cursor or at the page's offset
+	page, err := filters.pageQuery()
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return compareTasks(matches[i], matches[j], page.keys) < 0
+	})
+	rows := matches
+	if page.cursor != nil {
+		rows = []*Task{}
+		for _, task := range matches {
+			if afterCursor(task, page.keys, page.cursor.Values) {
+				rows = append(rows, task)
+			}
+		}
+	}
+	tasks := []*Task{}
+	if page.offset < len(rows) {
+		end := page.offset + page.limit
+		if end > len(rows) {
+			end = len(rows)
+		}
+		tasks = rows[page.offset:end]
+	}
+
+	var total *int
+	tagCounts := map[string]int{}
+	if filters.IncludeTotal {
+		count := len(matches)
+		total = &count
+
+		//counting the tags across every matching task, not just this page
+		for _, task := range matches {
+			for _, name := range task.Tags {
+				tagCounts[name]++
+			}
+		}
+	}
+
+	tasks, metadata := filters.finishPage(page, tasks, total)
+	if len(tagCounts) > 0 {
+		metadata.TagCounts = tagCounts
+	}
+	return tasks, metadata, nil
+}
+
+// the Search() method searches the user's tasks like TaskModel.Search(), words are not stemmed so only the simple language is supported
+// the whole description is highlighted rather than an excerpt of it
+func (m MemoryTaskStore) Search(ctx context.Context, userID int64, query string, language string, filters Filters) ([]*TaskSearchHit, Metadata, error) {
+	if language != "simple" {
+		return nil, Metadata{}, ErrUnsupportedLanguage
+	}
+	parsed := parseSearchQuery(query)
+
+	m.db.mu.RLock()
+	defer m.db.mu.RUnlock()
+
+	owner := TaskFilter{ownedBy(userID)}
+	hits := []*TaskSearchHit{}
+	for _, stored := range m.db.tasks {
+		task := m.copyTask(stored)
+		if !owner.match(task) {
+			continue
+		}
+		if rank, ok := parsed.match(textWords(task.Title), textWords(task.Descritpion)); ok {
+			hits = append(hits, &TaskSearchHit{Task: task, Rank: rank})
+		}
+	}
+
+	//best match first, ties go by id like the database's ORDER BY rank DESC, id
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Rank != hits[j].Rank {
+			return hits[i].Rank > hits[j].Rank
+		}
+		return hits[i].ID < hits[j].ID
+	})
+	totalRecords := len(hits)
+	start := min(filters.offSet(), totalRecords)
+	hits = hits[start:min(start+filters.limit(), totalRecords)]
+	for _, hit := range hits {
+		hit.Highlights.Title = parsed.highlight(hit.Title)
+		hit.Highlights.Description = parsed.highlight(hit.Descritpion)
+	}
+	return hits, calculateMetaData(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// textWords() splits text into lower case words the way the 'simple' text search configuration does
+func textWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// textMatches() stands in for to_tsvector('simple', text) @@ plainto_tsquery('simple', query), every word of the query has to appear in the text
+func textMatches(text, query string) bool {
+	if query == "" {
+		return true
+	}
+	queryWords := textWords(query)
+	if len(queryWords) == 0 {
+		return false
+	}
+
+	words := make(map[string]bool)
+	for _, word := range textWords(text) {
+		words[word] = true
+	}
+	for _, word := range queryWords {
+		if !words[word] {
+			return false
+		}
+	}
+	return true
+}
+
+// tagsMatch() reports whether the task carries any of the wanted tags, or all of them when matchAll is set
+func tagsMatch(taskTags, wanted []string, matchAll bool) bool {
+	found := 0
+	for _, name := range taskTags {
+		for _, w := range wanted {
+			if name == w {
+				found++
+				break
+			}
+		}
+	}
+	if matchAll {
+		return found >= len(wanted)
+	}
+	return found >= 1
+}
+
+// compareInt() returns -1, 0 or 1 as a is less than, equal to or greater than b
+func compareInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// Ancestors() returns the ids on the path from the given task up to its top level task, starting with the task itself
+func (m MemoryTaskStore) Ancestors(ctx context.Context, id int64) ([]int64, error) {
+	m.db.mu.RLock()
+	defer m.db.mu.RUnlock()
+
+	return m.ancestors(id)
+}
+
+// ancestors() is Ancestors() for a caller holding the lock
+func (m MemoryTaskStore) ancestors(id int64) ([]int64, error) {
+	ids := []int64{}
+	task, ok := m.db.tasks[id]
+	for ok && len(ids) < maxHierarchyWalk {
+		ids = append(ids, task.ID)
+		if task.ParentID == nil {
+			break
+		}
+		task, ok = m.db.tasks[*task.ParentID]
+	}
+	if len(ids) == 0 {
+		return nil, ErrRecordNotFound
+	}
+	return ids, nil
+}
+
+// children() returns the direct subtasks of a task sorted by id, the caller holds the lock
+func (m MemoryTaskStore) children(id int64) []*Task {
+	children := []*Task{}
+	for _, task := range m.db.tasks {
+		if task.ParentID != nil && *task.ParentID == id {
+			children = append(children, task)
+		}
+	}
+	sort.Slice(children, func(i, j int) bool {
+		return children[i].ID < children[j].ID
+	})
+	return children
+}
+
+// descendants() returns every subtask below a task, at most maxHierarchyWalk levels deep, the caller holds the lock
+func (m MemoryTaskStore) descendants(id int64) []*Task {
+	var found []*Task
+	level := m.children(id)
+	for depth := 0; depth <= maxHierarchyWalk && len(level) > 0; depth++ {
+		found = append(found, level...)
+		var next []*Task
+		for _, task := range level {
+			next = append(next, m.children(task.ID)...)
+		}
+		level = next
+	}
+	return found
+}
+
+// SubtreeHeight() returns the number of levels in the hierarchy rooted at the given task, a task without subtasks has a height of 1
+func (m MemoryTaskStore) SubtreeHeight(ctx context.Context, id int64) (int, error) {
+	m.db.mu.RLock()
+	defer m.db.mu.RUnlock()
+
+	return m.subtreeHeight(id), nil
+}
+
+// subtreeHeight() is SubtreeHeight() for a caller holding the lock
+func (m MemoryTaskStore) subtreeHeight(id int64) int {
+	if _, ok := m.db.tasks[id]; !ok {
+		return 0
+	}
+
+	height := 0
+	level := []int64{id}
+	for len(level) > 0 && height < maxHierarchyWalk {
+		height++
+		var next []int64
+		for _, parentID := range level {
+			for _, child := range m.children(parentID) {
+				next = append(next, child.ID)
+			}
+		}
+		level = next
+	}
+	return height
+}
+
+// GetSubtasks() returns the direct subtasks of the given task that are owned by the user sorted by id
+func (m MemoryTaskStore) GetSubtasks(ctx context.Context, id int64, userID int64) ([]*Task, error) {
+	m.db.mu.RLock()
+	defer m.db.mu.RUnlock()
+
+	tasks := []*Task{}
+	for _, child := range m.children(id) {
+		if owns(child, userID) {
+			tasks = append(tasks, m.copyTask(child))
+		}
+	}
+	return tasks, nil
+}
+
+// GetTree() retrieves a task owned by the user together with all of its subtasks nested under it
+// subtasks belonging to someone else are left out along with everything below them
+func (m MemoryTaskStore) GetTree(ctx context.Context, id int64, userID int64) (*Task, error) {
+	m.db.mu.RLock()
+	defer m.db.mu.RUnlock()
+
+	stored, ok := m.db.tasks[id]
+	if !ok || !owns(stored, userID) {
+		return nil, ErrRecordNotFound
+	}
+
+	root := m.copyTask(stored)
+	level := []*Task{root}
+	for depth := 0; depth < maxHierarchyWalk && len(level) > 0; depth++ {
+		var next []*Task
+		for _, parent := range level {
+			for _, child := range m.children(parent.ID) {
+				if !owns(child, userID) {
+					continue
+				}
+				subtask := m.copyTask(child)
+				parent.Subtasks = append(parent.Subtasks, subtask)
+				next = append(next, subtask)
+			}
+		}
+		level = next
+	}
+	return root, nil
+}
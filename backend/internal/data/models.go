@@ -2,8 +2,10 @@
 package data
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"time"
 )
 
 var (
@@ -11,14 +13,94 @@ var (
 	ErrEditConflict   = errors.New("edit conflict")
 )
 
+// TaskStore persists tasks, every method is scoped to the owner described on TaskModel
+type TaskStore interface {
+	Insert(ctx context.Context, task *Task) error
+	Get(ctx context.Context, id int64, userID int64) (*Task, error)
+	Update(ctx context.Context, task *Task, completion SubtaskCompletion) error
+	Delete(ctx context.Context, id int64, userID int64) error
+	GetAll(ctx context.Context, userID int64, filter TaskFilter, filters Filters) ([]*Task, Metadata, error)
+	Search(ctx context.Context, userID int64, query string, language string, filters Filters) ([]*TaskSearchHit, Metadata, error)
+
+	Ancestors(ctx context.Context, id int64) ([]int64, error)
+	SubtreeHeight(ctx context.Context, id int64) (int, error)
+	GetSubtasks(ctx context.Context, id int64, userID int64) ([]*Task, error)
+	GetTree(ctx context.Context, id int64, userID int64) (*Task, error)
+}
+
+// TagStore persists the tags each user labels their tasks with, scoped to the owner like TaskStore
+type TagStore interface {
+	Insert(ctx context.Context, tag *Tag) error
+	Get(ctx context.Context, id int64, userID int64) (*Tag, error)
+	Update(ctx context.Context, tag *Tag) error
+	Delete(ctx context.Context, id int64, userID int64) error
+	GetAll(ctx context.Context, userID int64) ([]*Tag, error)
+}
+
+// ListStore persists the lists that group tasks, scoped to the owner like TaskStore
+type ListStore interface {
+	Insert(ctx context.Context, list *List) error
+	Get(ctx context.Context, id int64, userID int64) (*List, error)
+	Update(ctx context.Context, list *List) error
+	Delete(ctx context.Context, id int64, userID int64, cascade bool) error
+	GetAll(ctx context.Context, userID int64) ([]*List, error)
+}
+
+// UserStore persists user accounts
+type UserStore interface {
+	Insert(ctx context.Context, user *User) error
+	GetByEmail(ctx context.Context, email string) (*User, error)
+	Update(ctx context.Context, user *User) error
+	GetForToken(ctx context.Context, tokenScope, tokenPlaintext string) (*User, error)
+	Register(ctx context.Context, user *User, codes []string, activationTTL time.Duration) (*Token, error)
+}
+
+// TokenStore persists the hashes of the tokens issued to users
+type TokenStore interface {
+	New(ctx context.Context, userID int64, ttl time.Duration, scope string) (*Token, error)
+	Insert(ctx context.Context, token *Token) error
+	DeleteAllForUser(ctx context.Context, scope string, userID int64) error
+}
+
+// PermissionStore persists the permission codes granted to users
+type PermissionStore interface {
+	GetAllForUser(ctx context.Context, userID int64) (Permissions, error)
+	AddForUser(ctx context.Context, userID int64, codes ...string) error
+}
+
+// HealthStore reports on the storage backend for the readiness check
+type HealthStore interface {
+	Ping(ctx context.Context) error
+	MigrationVersion(ctx context.Context) (*MigrationStatus, error)
+}
+
+// queryer is met by both *sql.DB and *sql.Tx so a query can run on its own or as part of a larger transaction
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
 // A wrapper for out data models
 type Models struct {
-	Tasks TaskModel
+	Tasks       TaskStore
+	Tags        TagStore
+	Lists       ListStore
+	Users       UserStore
+	Tokens      TokenStore
+	Permissions PermissionStore
+	Health      HealthStore
 }
 
-// NewModels() allows us to create a new model
-func NewModels(db *sql.DB) Models {
+// NewModels() allows us to create a new model backed by PostgreSQL
+func NewModels(db *sql.DB, queryTimeout time.Duration) Models {
 	return Models{
-		Tasks: TaskModel{DB: db},
+		Tasks:       TaskModel{DB: db, Timeout: queryTimeout},
+		Tags:        TagModel{DB: db, Timeout: queryTimeout},
+		Lists:       ListModel{DB: db, Timeout: queryTimeout},
+		Users:       UserModel{DB: db, Timeout: queryTimeout},
+		Tokens:      TokenModel{DB: db, Timeout: queryTimeout},
+		Permissions: PermissionModel{DB: db, Timeout: queryTimeout},
+		Health:      HealthModel{DB: db},
 	}
 }
@@ -0,0 +1,91 @@
+// File: todoApi/backend/internal/data/permissions.go
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// the permission codes that can be granted to a user
+const (
+	PermissionTodoRead  = "todo:read"
+	PermissionTodoWrite = "todo:write"
+	PermissionTodoAdmin = "todo:admin"
+)
+
+// Permissions holds the permission codes granted to a single user
+type Permissions []string
+
+// Include() checks if the slice contains a specific permission code
+func (p Permissions) Include(code string) bool {
+	for i := range p {
+		if code == p[i] {
+			return true
+		}
+	}
+	return false
+}
+
+type PermissionModel struct {
+	DB      *sql.DB
+	Timeout time.Duration //the longest a single query may run
+}
+
+// GetAllForUser() returns every permission code granted to the user
+func (m PermissionModel) GetAllForUser(ctx context.Context, userID int64) (Permissions, error) {
+	query := `
+		SELECT permissions.code
+		FROM permissions
+		INNER JOIN users_permissions ON users_permissions.permission_id = permissions.id
+		WHERE users_permissions.user_id = $1
+	`
+
+	//creating the context
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	//Cleaning up to prevent memory leaks
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var permissions Permissions
+	for rows.Next() {
+		var permission string
+		if err := rows.Scan(&permission); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, permission)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return permissions, nil
+}
+
+// AddForUser() grants the permission codes to the user
+func (m PermissionModel) AddForUser(ctx context.Context, userID int64, codes ...string) error {
+	//creating the context
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	//Cleaning up to prevent memory leaks
+	defer cancel()
+
+	return addPermissions(ctx, m.DB, userID, codes)
+}
+
+// addPermissions() grants the permission codes to the user, q is the pool or the caller's transaction
+func addPermissions(ctx context.Context, q queryer, userID int64, codes []string) error {
+	query := `
+		INSERT INTO users_permissions
+		SELECT $1, permissions.id FROM permissions WHERE permissions.code = ANY($2)
+		ON CONFLICT DO NOTHING
+	`
+
+	_, err := q.ExecContext(ctx, query, userID, pq.Array(codes))
+	return err
+}
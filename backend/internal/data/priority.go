@@ -0,0 +1,55 @@
+// File: todoApi/backend/internal/data/priority.go
+package data
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Priority is the importance of a task, it is stored as a small integer so that the database can sort on it
+type Priority int16
+
+// The priority levels from least to most important
+const (
+	PriorityInvalid Priority = -1
+	PriorityNone    Priority = 0
+	PriorityLow     Priority = 1
+	PriorityMedium  Priority = 2
+	PriorityHigh    Priority = 3
+	PriorityUrgent  Priority = 4
+)
+
+// PriorityNames holds the accepted names for every priority level, in order
+var PriorityNames = []string{"none", "low", "medium", "high", "urgent"}
+
+// ParsePriority() converts a priority name to a Priority, an empty name is treated as none
+// PriorityInvalid is returned for names that are not recognised
+func ParsePriority(name string) Priority {
+	if name == "" {
+		return PriorityNone
+	}
+	for i := range PriorityNames {
+		if name == PriorityNames[i] {
+			return Priority(i)
+		}
+	}
+	return PriorityInvalid
+}
+
+// Valid() checks that the priority is one of the known levels
+func (p Priority) Valid() bool {
+	return p >= PriorityNone && p <= PriorityUrgent
+}
+
+// String() returns the name of the priority level
+func (p Priority) String() string {
+	if !p.Valid() {
+		return fmt.Sprintf("Priority(%d)", int16(p))
+	}
+	return PriorityNames[p]
+}
+
+// MarshalJSON() writes the priority as its name rather than its number
+func (p Priority) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(p.String())), nil
+}
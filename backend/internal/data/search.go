@@ -0,0 +1,399 @@
+// File: todoApi/backend/internal/data/search.go
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+
+	"github.com/lib/pq"
+	"todo.michaelgomez.net/internal/validator"
+)
+
+var (
+	ErrUnsupportedLanguage = errors.New("unsupported search language")
+)
+
+// SearchLanguages are the text search configurations a search can stem its words with
+// "simple" only lower cases the words and is the only one with an index, see migration 000012
+var SearchLanguages = []string{"simple", "danish", "dutch", "english", "finnish", "french", "german", "hungarian", "italian", "norwegian", "portuguese", "romanian", "russian", "spanish", "swedish", "turkish"}
+
+// the <mark> tags put around the matched words of a headline
+const (
+	highlightStart = "<mark>"
+	highlightStop  = "</mark>"
+)
+
+// TaskSearchHit is a task found by a search along with how well it matched and the matched words highlighted
+type TaskSearchHit struct {
+	*Task
+	Rank       float64 `json:"rank"`
+	Highlights struct {
+		Title       string `json:"title"`
+		Description string `json:"description"` //an excerpt around the matches, the whole description when it is short
+	} `json:"highlights"`
+}
+
+// ValidateSearchQuery() checks the q parameter of a search
+func ValidateSearchQuery(v *validator.Validator, query string) {
+	v.Check(query != "", "q", "must be provided")
+	v.Check(len(query) <= 500, "q", "must not be more than 500 bytes long")
+	v.Check(query == "" || parseSearchQuery(query).searchable(), "q", "must look for a word that is not excluded with - in every alternative")
+}
+
+// searchTerm is a word or a quoted phrase of a search query
+type searchTerm struct {
+	words   []string //a single word, or the words of a phrase in order
+	prefix  bool     //word* also matches the longer words starting with word
+	exclude bool     //-word leaves out the tasks containing word
+}
+
+// searchQuery is a search box query read the way websearch_to_tsquery() reads it: words have to appear,
+// "quoted text" has to appear as a phrase, -word must not appear and "or" separates alternatives
+// on top of that word* matches every word starting with word, these prefix terms apply to every alternative
+type searchQuery struct {
+	alternatives [][]searchTerm //at least one has to match, every term of an alternative has to match
+	prefixes     []searchTerm
+	websearch    string //the query without its prefix terms, for websearch_to_tsquery()
+}
+
+// parseSearchQuery() splits a search box query into its terms
+func parseSearchQuery(query string) searchQuery {
+	var q searchQuery
+	var websearch []string
+	current := []searchTerm{}
+	for query != "" {
+		query = strings.TrimLeftFunc(query, unicode.IsSpace)
+		if query == "" {
+			break
+		}
+
+		//reading the next token, a quoted phrase runs to the closing quote or to the end of the query
+		exclude := strings.HasPrefix(query, "-")
+		rest := strings.TrimPrefix(query, "-")
+		var token string
+		quoted := strings.HasPrefix(rest, `"`)
+		if quoted {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				token, query = rest[1:], ""
+			} else {
+				token, query = rest[1:end+1], rest[end+2:]
+			}
+		} else {
+			end := strings.IndexFunc(rest, func(r rune) bool { return unicode.IsSpace(r) || r == '"' })
+			if end < 0 {
+				end = len(rest)
+			}
+			token, query = rest[:end], rest[end:]
+		}
+		raw := token
+		if quoted {
+			raw = `"` + token + `"`
+		}
+		if exclude {
+			raw = "-" + raw
+		}
+
+		switch words := textWords(token); {
+		case !quoted && !exclude && strings.EqualFold(token, "or"):
+			if len(current) > 0 {
+				q.alternatives = append(q.alternatives, current)
+				current = []searchTerm{}
+			}
+			websearch = append(websearch, raw)
+		case !quoted && strings.HasSuffix(token, "*") && len(words) == 1:
+			q.prefixes = append(q.prefixes, searchTerm{words: words, prefix: true, exclude: exclude})
+		case len(words) > 0:
+			current = append(current, searchTerm{words: words, exclude: exclude})
+			websearch = append(websearch, raw)
+		}
+	}
+	if len(current) > 0 {
+		q.alternatives = append(q.alternatives, current)
+	}
+	if len(q.alternatives) > 0 {
+		q.websearch = strings.Join(websearch, " ")
+	}
+	return q
+}
+
+// The searchable() method reports whether the query looks for something rather than only excluding words
+// every alternative needs a word that is not excluded as SQLite cannot search for the absence of a word alone,
+// a prefix term that is not excluded applies to every alternative so it is enough on its own
+func (q searchQuery) searchable() bool {
+	looksFor := func(terms []searchTerm) bool {
+		for _, term := range terms {
+			if !term.exclude {
+				return true
+			}
+		}
+		return false
+	}
+	if looksFor(q.prefixes) {
+		return true
+	}
+	if len(q.alternatives) == 0 {
+		return false
+	}
+	for _, terms := range q.alternatives {
+		if !looksFor(terms) {
+			return false
+		}
+	}
+	return true
+}
+
+// The tsquery() method returns the SQL of the tsquery for config, its text is bound through b
+func (q searchQuery) tsquery(b *whereBuilder, config string) string {
+	var queries []string
+	if q.websearch != "" {
+		queries = append(queries, fmt.Sprintf("websearch_to_tsquery(%s, %s)", config, b.bind(q.websearch)))
+	}
+	if len(q.prefixes) > 0 {
+		terms := make([]string, len(q.prefixes))
+		for i, term := range q.prefixes {
+			terms[i] = "'" + strings.ReplaceAll(strings.ReplaceAll(term.words[0], `\`, `\\`), "'", "''") + "':*"
+			if term.exclude {
+				terms[i] = "!" + terms[i]
+			}
+		}
+		queries = append(queries, fmt.Sprintf("to_tsquery(%s, %s)", config, b.bind(strings.Join(terms, " & "))))
+	}
+	return strings.Join(queries, " && ")
+}
+
+// The fts5() method returns the query as an FTS5 query on every column of task_search
+// the prefix terms are repeated in every alternative, that way an alternative that only excludes words still has something to look for
+func (q searchQuery) fts5() string {
+	if len(q.alternatives) == 0 {
+		return fts5AllOf(q.prefixes)
+	}
+	alternatives := make([]string, len(q.alternatives))
+	for i, terms := range q.alternatives {
+		alternatives[i] = fts5AllOf(append(append([]searchTerm{}, q.prefixes...), terms...))
+	}
+	if len(alternatives) == 1 {
+		return alternatives[0]
+	}
+	return "(" + strings.Join(alternatives, ") OR (") + ")"
+}
+
+// fts5AllOf() joins the terms so that all of them have to match, the excluded terms through NOT
+// FTS5 has no unary NOT so there has to be at least one term that is not excluded
+func fts5AllOf(terms []searchTerm) string {
+	var expressions, excluded []string
+	for _, term := range terms {
+		//every word is quoted so that nothing the user typed is read as FTS5 syntax
+		phrase := `"` + strings.ReplaceAll(strings.Join(term.words, " "), `"`, `""`) + `"`
+		if term.prefix {
+			phrase += "*"
+		}
+		if term.exclude {
+			excluded = append(excluded, phrase)
+		} else {
+			expressions = append(expressions, phrase)
+		}
+	}
+	expression := strings.Join(expressions, " AND ")
+	for _, phrase := range excluded {
+		expression += " NOT " + phrase
+	}
+	return expression
+}
+
+// The in() method reports whether the term appears in the words of a text
+func (t searchTerm) in(words []string) bool {
+	for i := 0; i+len(t.words) <= len(words); i++ {
+		found := true
+		for j, word := range t.words {
+			if word != words[i+j] && !(t.prefix && strings.HasPrefix(words[i+j], word)) {
+				found = false
+				break
+			}
+		}
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+// The match() method checks the query against the words of a task's title and description
+// the rank stands in for ts_rank(), every term found counts 1 in the title and 0.4 in the description
+func (q searchQuery) match(title, description []string) (float64, bool) {
+	allOf := func(terms []searchTerm) (float64, bool) {
+		rank := 0.0
+		for _, term := range terms {
+			inTitle, inDescription := term.in(title), term.in(description)
+			switch {
+			case term.exclude && (inTitle || inDescription), !term.exclude && !inTitle && !inDescription:
+				return 0, false
+			case term.exclude:
+			case inTitle:
+				rank += 1
+			default:
+				rank += 0.4
+			}
+		}
+		return rank, true
+	}
+
+	rank, ok := allOf(q.prefixes)
+	if !ok || len(q.alternatives) == 0 {
+		return rank, ok
+	}
+	//the best matching alternative counts
+	matched, best := false, 0.0
+	for _, terms := range q.alternatives {
+		if alternativeRank, ok := allOf(terms); ok {
+			matched, best = true, max(best, alternativeRank)
+		}
+	}
+	return rank + best, matched
+}
+
+// The highlight() method HTML escapes a text and puts <mark> tags around the words the query looks for, see ts_headline()
+func (q searchQuery) highlight(text string) string {
+	var wanted []searchTerm
+	for _, terms := range append([][]searchTerm{q.prefixes}, q.alternatives...) {
+		for _, term := range terms {
+			if !term.exclude {
+				for _, word := range term.words {
+					wanted = append(wanted, searchTerm{words: []string{word}, prefix: term.prefix})
+				}
+			}
+		}
+	}
+
+	var b strings.Builder
+	for text != "" {
+		start := strings.IndexFunc(text, isWordRune)
+		if start < 0 {
+			start = len(text)
+		}
+		b.WriteString(html.EscapeString(text[:start]))
+		text = text[start:]
+
+		end := strings.IndexFunc(text, func(r rune) bool { return !isWordRune(r) })
+		if end < 0 {
+			end = len(text)
+		}
+		word := text[:end]
+		text = text[end:]
+		if word == "" {
+			continue
+		}
+
+		marked := false
+		for _, term := range wanted {
+			if term.in(textWords(word)) {
+				marked = true
+				break
+			}
+		}
+		if marked {
+			b.WriteString(highlightStart + html.EscapeString(word) + highlightStop)
+		} else {
+			b.WriteString(html.EscapeString(word))
+		}
+	}
+	return b.String()
+}
+
+// isWordRune() reports whether r is part of a word, as textWords() splits them
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// escapeHeadline() HTML escapes a headline from ts_headline() or FTS5 while keeping its <mark> tags
+// the text of a task is not escaped by the database so this is what makes a headline safe to show as HTML
+func escapeHeadline(headline string) string {
+	escaped := html.EscapeString(headline)
+	return strings.NewReplacer(html.EscapeString(highlightStart), highlightStart, html.EscapeString(highlightStop), highlightStop).Replace(escaped)
+}
+
+// the ts_headline() options, the whole title is returned and the description is cut down to the words around the matches
+const (
+	titleHeadlineOptions       = "HighlightAll=true, StartSel=" + highlightStart + ", StopSel=" + highlightStop
+	descriptionHeadlineOptions = "MinWords=15, MaxWords=35, StartSel=" + highlightStart + ", StopSel=" + highlightStop
+)
+
+// the Search() method returns a page of the user's tasks matching a search box query, best match first
+// title and description are searched together with the title weighed above the description, language is one of
+// SearchLanguages and is written into the query as a literal so that the index on the simple configuration can be used
+func (m TaskModel) Search(ctx context.Context, userID int64, query string, language string, filters Filters) ([]*TaskSearchHit, Metadata, error) {
+	if !validator.In(language, SearchLanguages...) {
+		return nil, Metadata{}, ErrUnsupportedLanguage
+	}
+	config := fmt.Sprintf("'%s'::regconfig", language)
+	document := fmt.Sprintf(`setweight(to_tsvector(%[1]s, title), 'A') || setweight(to_tsvector(%[1]s, description), 'B')`, config)
+
+	b := &whereBuilder{}
+	tsquery := parseSearchQuery(query).tsquery(b, config)
+	where := b.where(userID, nil)
+
+	//constructing the query
+	sqlQuery := fmt.Sprintf(`
+		SELECT count(*) OVER(), id, list_id, parent_id, COALESCE(user_id, 0), created_at, title, description, completed, priority, due_at, remind_at, %[1]s, version,
+			ts_rank(%[2]s, search.query) AS rank,
+			ts_headline(%[3]s, title, search.query, '%[4]s'),
+			ts_headline(%[3]s, description, search.query, '%[5]s')
+		FROM task_list, (SELECT %[6]s AS query) AS search
+		%[7]s
+		AND (%[2]s) @@ search.query
+		ORDER BY rank DESC, id
+		LIMIT %[8]s OFFSET %[9]s
+	`, tagsColumn, document, config, titleHeadlineOptions, descriptionHeadlineOptions, tsquery, where, b.bind(filters.limit()), b.bind(filters.offSet()))
+
+	//creating the time out context
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	//Execute the query
+	rows, err := m.DB.QueryContext(ctx, sqlQuery, b.args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	hits := []*TaskSearchHit{}
+	for rows.Next() {
+		hit := &TaskSearchHit{Task: &Task{}}
+		err := rows.Scan(
+			&totalRecords,
+			&hit.ID,
+			&hit.ListID,
+			&hit.ParentID,
+			&hit.UserID,
+			&hit.CreatedAt,
+			&hit.Title,
+			&hit.Descritpion,
+			&hit.Completed,
+			&hit.Priority,
+			&hit.DueAt,
+			&hit.RemindAt,
+			pq.Array(&hit.Tags),
+			&hit.Version,
+			&hit.Rank,
+			&hit.Highlights.Title,
+			&hit.Highlights.Description,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		hit.Highlights.Title = escapeHeadline(hit.Highlights.Title)
+		hit.Highlights.Description = escapeHeadline(hit.Highlights.Description)
+		hits = append(hits, hit)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetaData(totalRecords, filters.Page, filters.PageSize)
+	return hits, metadata, nil
+}
@@ -0,0 +1,65 @@
+// File: todoApi/backend/internal/data/search_test.go
+package data
+
+import "testing"
+
+func TestSearchQuery(t *testing.T) {
+	tests := []struct {
+		query      string
+		searchable bool
+		fts5       string //only checked for searchable queries
+	}{
+		{query: "milk", searchable: true, fts5: `"milk"`},
+		{query: `"buy milk" -later`, searchable: true, fts5: `"buy milk" NOT "later"`},
+		{query: "alp* foo", searchable: true, fts5: `"alp"* AND "foo"`},
+		{query: "alp*", searchable: true, fts5: `"alp"*`},
+		{query: "-task", searchable: false},
+		{query: "-alp*", searchable: false},
+		{query: "milk or -bread", searchable: false},
+
+		//a prefix term is looked for in every alternative, so the alternatives may only exclude words
+		{query: "alp* -task", searchable: true, fts5: `"alp"* NOT "task"`},
+		{query: "-task alp*", searchable: true, fts5: `"alp"* NOT "task"`},
+		{query: "alp* -task -done", searchable: true, fts5: `"alp"* NOT "task" NOT "done"`},
+		{query: "alp* -task or -done", searchable: true, fts5: `("alp"* NOT "task") OR ("alp"* NOT "done")`},
+		{query: "alp* milk or -done", searchable: true, fts5: `("alp"* AND "milk") OR ("alp"* NOT "done")`},
+		{query: "-alp* milk", searchable: true, fts5: `"milk" NOT "alp"*`},
+		{query: "-alp* -task", searchable: false},
+	}
+
+	for _, tt := range tests {
+		q := parseSearchQuery(tt.query)
+		if got := q.searchable(); got != tt.searchable {
+			t.Errorf("%q: searchable() = %v, want %v", tt.query, got, tt.searchable)
+		}
+		if !tt.searchable {
+			continue
+		}
+		if got := q.fts5(); got != tt.fts5 {
+			t.Errorf("%q: fts5() = %s, want %s", tt.query, got, tt.fts5)
+		}
+	}
+}
+
+func TestSearchQueryMatch(t *testing.T) {
+	tests := []struct {
+		query string
+		title string
+		want  bool
+	}{
+		{query: "alp* -task", title: "alpha list", want: true},
+		{query: "alp* -task", title: "alpha task", want: false},
+		{query: "alp* -task", title: "beta list", want: false},
+		{query: "alp* -task or -done", title: "alpha task", want: true},
+		{query: "alp* -task or -done", title: "alpha task done", want: false},
+		{query: "-alp* milk", title: "buy milk", want: true},
+		{query: "-alp* milk", title: "alpine milk", want: false},
+	}
+
+	for _, tt := range tests {
+		_, got := parseSearchQuery(tt.query).match(textWords(tt.title), nil)
+		if got != tt.want {
+			t.Errorf("%q on %q: match() = %v, want %v", tt.query, tt.title, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,778 @@
+// File: todoApi/backend/internal/data/sqlite.go
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// the SQLite models only use database/sql so this package does not depend on a driver
+// the driver is registered by the binary, see cmd/api/sqlite.go
+
+// NewSQLiteModels() returns models backed by a SQLite database migrated with migrations/sqlite
+func NewSQLiteModels(db *sql.DB, queryTimeout time.Duration) Models {
+	return Models{
+		Tasks:       SQLiteTaskModel{DB: db, Timeout: queryTimeout},
+		Tags:        SQLiteTagModel{DB: db, Timeout: queryTimeout},
+		Lists:       SQLiteListModel{DB: db, Timeout: queryTimeout},
+		Users:       SQLiteUserModel{DB: db, Timeout: queryTimeout},
+		Tokens:      SQLiteTokenModel{DB: db, Timeout: queryTimeout},
+		Permissions: SQLitePermissionModel{DB: db, Timeout: queryTimeout},
+		Health:      SQLiteHealthModel{DB: db},
+	}
+}
+
+// sqliteTimeLayout is how times are stored, fixed width UTC text sorts and compares the same way as the times themselves
+const sqliteTimeLayout = "2006-01-02 15:04:05"
+
+// sqliteTimeValue() formats t for a timestamp column, rounded to the second like a timestamp(0) column in Postgres
+func sqliteTimeValue(t time.Time) string {
+	return t.Round(time.Second).UTC().Format(sqliteTimeLayout)
+}
+
+// sqliteNullTime() formats an optional time, nil is stored as NULL
+func sqliteNullTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return sqliteTimeValue(*t)
+}
+
+// sqliteTime scans a timestamp column into dest, a *time.Time or a **time.Time for nullable columns
+// drivers either hand back the stored text or parse it themselves so both are accepted
+type sqliteTime struct {
+	dest interface{}
+}
+
+// Scan() implements sql.Scanner
+func (s sqliteTime) Scan(src interface{}) error {
+	var t time.Time
+	switch v := src.(type) {
+	case nil:
+		if dest, ok := s.dest.(**time.Time); ok {
+			*dest = nil
+			return nil
+		}
+		return errors.New("sqlite: NULL in a non-null timestamp column")
+	case time.Time:
+		t = v.UTC()
+	case string, []byte:
+		var err error
+		t, err = time.Parse(sqliteTimeLayout, fmt.Sprintf("%s", v))
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("sqlite: cannot scan %T into a timestamp", src)
+	}
+
+	switch dest := s.dest.(type) {
+	case *time.Time:
+		*dest = t
+	case **time.Time:
+		*dest = &t
+	default:
+		return fmt.Errorf("sqlite: cannot scan a timestamp into %T", s.dest)
+	}
+	return nil
+}
+
+// sqlitePlaceholders() returns "?, ?, ?" with n placeholders for an IN list
+func sqlitePlaceholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// isSQLiteUniqueViolation() checks for a failed unique constraint
+// the error is matched on its message, which SQLite keeps stable, so that no driver has to be imported here
+func isSQLiteUniqueViolation(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed")
+}
+
+// SQLiteTagModel stores tags in SQLite
+type SQLiteTagModel struct {
+	DB      *sql.DB
+	Timeout time.Duration //the longest a single query may run
+}
+
+// Insert() creates a new tag
+func (m SQLiteTagModel) Insert(ctx context.Context, tag *Tag) error {
+	query := `
+		INSERT INTO tags (created_at, user_id, name)
+		VALUES (?, NULLIF(?, 0), ?)
+		RETURNING id, created_at, version
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, sqliteTimeValue(time.Now()), tag.UserID, tag.Name).Scan(&tag.ID, sqliteTime{&tag.CreatedAt}, &tag.Version)
+	if err != nil {
+		switch {
+		case isSQLiteUniqueViolation(err):
+			return ErrDuplicateTag
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// Get() returns a tag owned by the user along with the number of tasks carrying it
+func (m SQLiteTagModel) Get(ctx context.Context, id int64, userID int64) (*Tag, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, COALESCE(user_id, 0), created_at, name,
+		(SELECT COUNT(*) FROM task_tags WHERE tag_id = tags.id),
+		version
+		FROM tags
+		WHERE id = ?1
+		AND %s
+	`, fmt.Sprintf(sqliteOwner, 2))
+
+	var tag Tag
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id, userID).Scan(
+		&tag.ID,
+		&tag.UserID,
+		sqliteTime{&tag.CreatedAt},
+		&tag.Name,
+		&tag.TaskCount,
+		&tag.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &tag, nil
+}
+
+// Update() renames a tag, using the version for optimistic locking
+func (m SQLiteTagModel) Update(ctx context.Context, tag *Tag) error {
+	query := `
+		UPDATE tags
+		SET name = ?, version = version + 1
+		WHERE id = ?
+		AND version = ?
+		AND user_id IS NULLIF(?, 0)
+		RETURNING version
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, tag.Name, tag.ID, tag.Version, tag.UserID).Scan(&tag.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		case isSQLiteUniqueViolation(err):
+			return ErrDuplicateTag
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete() removes a tag owned by the user from every task and deletes it
+func (m SQLiteTagModel) Delete(ctx context.Context, id int64, userID int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := fmt.Sprintf(`DELETE FROM tags WHERE id = ?1 AND %s`, fmt.Sprintf(sqliteOwner, 2))
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetAll() returns the user's tags sorted by name
+func (m SQLiteTagModel) GetAll(ctx context.Context, userID int64) ([]*Tag, error) {
+	query := fmt.Sprintf(`
+		SELECT id, COALESCE(user_id, 0), created_at, name,
+		(SELECT COUNT(*) FROM task_tags WHERE tag_id = tags.id),
+		version
+		FROM tags
+		WHERE %s
+		ORDER BY name ASC, id ASC
+	`, fmt.Sprintf(sqliteOwner, 1))
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []*Tag{}
+	for rows.Next() {
+		var tag Tag
+		err := rows.Scan(
+			&tag.ID,
+			&tag.UserID,
+			sqliteTime{&tag.CreatedAt},
+			&tag.Name,
+			&tag.TaskCount,
+			&tag.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, &tag)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// sqliteSetTaskTags() replaces the tags of a task with its owner's tags, creating the tags that do not exist yet
+func sqliteSetTaskTags(ctx context.Context, tx *sql.Tx, taskID int64, userID int64, names []string) error {
+	createdAt := sqliteTimeValue(time.Now())
+	for _, name := range names {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO tags (created_at, user_id, name) VALUES (?, NULLIF(?, 0), ?)
+			ON CONFLICT (COALESCE(user_id, 0), name) DO NOTHING
+		`, createdAt, userID, name)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := tx.ExecContext(ctx, `DELETE FROM task_tags WHERE task_id = ?`, taskID)
+	if err != nil || len(names) == 0 {
+		return err
+	}
+
+	args := []interface{}{taskID, userID}
+	for _, name := range names {
+		args = append(args, name)
+	}
+	query := fmt.Sprintf(`
+		INSERT INTO task_tags (task_id, tag_id)
+		SELECT ?, id FROM tags
+		WHERE user_id IS NULLIF(?, 0)
+		AND name IN (%s)
+	`, sqlitePlaceholders(len(names)))
+	_, err = tx.ExecContext(ctx, query, args...)
+	return err
+}
+
+// SQLiteListModel stores lists in SQLite
+type SQLiteListModel struct {
+	DB      *sql.DB
+	Timeout time.Duration //the longest a single query may run
+}
+
+// Insert() creates a new list
+func (m SQLiteListModel) Insert(ctx context.Context, list *List) error {
+	query := `
+		INSERT INTO lists (created_at, user_id, name, description)
+		VALUES (?, NULLIF(?, 0), ?, ?)
+		RETURNING id, created_at, version
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	args := []interface{}{sqliteTimeValue(time.Now()), list.UserID, list.Name, list.Description}
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&list.ID, sqliteTime{&list.CreatedAt}, &list.Version)
+}
+
+// Get() returns a list owned by the user along with the number of tasks in it
+func (m SQLiteListModel) Get(ctx context.Context, id int64, userID int64) (*List, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, COALESCE(user_id, 0), created_at, name, description,
+		(SELECT COUNT(*) FROM task_list WHERE list_id = lists.id),
+		version
+		FROM lists
+		WHERE id = ?1
+		AND %s
+	`, fmt.Sprintf(sqliteOwner, 2))
+
+	var list List
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id, userID).Scan(
+		&list.ID,
+		&list.UserID,
+		sqliteTime{&list.CreatedAt},
+		&list.Name,
+		&list.Description,
+		&list.TaskCount,
+		&list.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &list, nil
+}
+
+// Update() edits a list, using the version for optimistic locking
+func (m SQLiteListModel) Update(ctx context.Context, list *List) error {
+	query := `
+		UPDATE lists
+		SET name = ?, description = ?, version = version + 1
+		WHERE id = ?
+		AND version = ?
+		AND user_id IS NULLIF(?, 0)
+		RETURNING version
+	`
+	args := []interface{}{list.Name, list.Description, list.ID, list.Version, list.UserID}
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&list.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete() removes a list owned by the user, the user's tasks in it are deleted with it when cascade is set and otherwise it must hold none
+func (m SQLiteListModel) Delete(ctx context.Context, id int64, userID int64, cascade bool) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	//the driver begins transactions with BEGIN IMMEDIATE, so the write lock is held from here on and no FOR UPDATE is needed
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	owner := fmt.Sprintf(sqliteOwner, 2)
+
+	var exists bool
+	err = tx.QueryRowContext(ctx, `SELECT TRUE FROM lists WHERE id = ?1 AND `+owner, id, userID).Scan(&exists)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	if cascade {
+		_, err = tx.ExecContext(ctx, `DELETE FROM task_list WHERE list_id = ?1 AND `+owner, id, userID)
+		if err != nil {
+			return err
+		}
+	} else {
+		var taskCount int
+		err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM task_list WHERE list_id = ?1 AND `+owner, id, userID).Scan(&taskCount)
+		if err != nil {
+			return err
+		}
+		if taskCount > 0 {
+			return ErrListNotEmpty
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE task_list SET list_id = NULL, version = version + 1 WHERE list_id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM lists WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetAll() returns the user's lists sorted by name
+func (m SQLiteListModel) GetAll(ctx context.Context, userID int64) ([]*List, error) {
+	query := fmt.Sprintf(`
+		SELECT id, COALESCE(user_id, 0), created_at, name, description,
+		(SELECT COUNT(*) FROM task_list WHERE list_id = lists.id),
+		version
+		FROM lists
+		WHERE %s
+		ORDER BY name ASC, id ASC
+	`, fmt.Sprintf(sqliteOwner, 1))
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lists := []*List{}
+	for rows.Next() {
+		var list List
+		err := rows.Scan(
+			&list.ID,
+			&list.UserID,
+			sqliteTime{&list.CreatedAt},
+			&list.Name,
+			&list.Description,
+			&list.TaskCount,
+			&list.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+		lists = append(lists, &list)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return lists, nil
+}
+
+// SQLiteUserModel stores user accounts in SQLite, emails are compared case-insensitively like the citext column
+type SQLiteUserModel struct {
+	DB      *sql.DB
+	Timeout time.Duration //the longest a single query may run
+}
+
+// Insert() creates a new user
+func (m SQLiteUserModel) Insert(ctx context.Context, user *User) error {
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return sqliteInsertUser(ctx, m.DB, user)
+}
+
+// Register() creates a new user together with their permissions and an activation token in a single transaction
+func (m SQLiteUserModel) Register(ctx context.Context, user *User, codes []string, activationTTL time.Duration) (*Token, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	err = sqliteInsertUser(ctx, tx, user)
+	if err != nil {
+		return nil, err
+	}
+	err = sqliteAddPermissions(ctx, tx, user.ID, codes)
+	if err != nil {
+		return nil, err
+	}
+	token, err := generateToken(user.ID, activationTTL, ScopeActivation)
+	if err != nil {
+		return nil, err
+	}
+	err = sqliteInsertToken(ctx, tx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// sqliteInsertUser() writes a new user, q is the pool or the caller's transaction
+func sqliteInsertUser(ctx context.Context, q queryer, user *User) error {
+	query := `
+		INSERT INTO users (created_at, name, email, password_hash, activated)
+		VALUES (?, ?, ?, ?, ?)
+		RETURNING id, created_at, version
+	`
+	args := []interface{}{sqliteTimeValue(time.Now()), user.Name, user.Email, user.Password.hash, user.Activated}
+
+	err := q.QueryRowContext(ctx, query, args...).Scan(&user.ID, sqliteTime{&user.CreatedAt}, &user.Version)
+	if err != nil {
+		switch {
+		case isSQLiteUniqueViolation(err):
+			return ErrDuplicateEmail
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// GetByEmail() returns the user with the email
+func (m SQLiteUserModel) GetByEmail(ctx context.Context, email string) (*User, error) {
+	query := `
+		SELECT id, created_at, name, email, password_hash, activated, version
+		FROM users
+		WHERE email = ?
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return scanSQLiteUser(m.DB.QueryRowContext(ctx, query, email))
+}
+
+// Update() edits a user, using the version for optimistic locking
+func (m SQLiteUserModel) Update(ctx context.Context, user *User) error {
+	query := `
+		UPDATE users
+		SET name = ?, email = ?, password_hash = ?, activated = ?, version = version + 1
+		WHERE id = ? AND version = ?
+		RETURNING version
+	`
+	args := []interface{}{user.Name, user.Email, user.Password.hash, user.Activated, user.ID, user.Version}
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&user.Version)
+	if err != nil {
+		switch {
+		case isSQLiteUniqueViolation(err):
+			return ErrDuplicateEmail
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// GetForToken() returns the user holding an unexpired token of the scope
+func (m SQLiteUserModel) GetForToken(ctx context.Context, tokenScope, tokenPlaintext string) (*User, error) {
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+
+	query := `
+		SELECT users.id, users.created_at, users.name, users.email, users.password_hash, users.activated, users.version
+		FROM users
+		INNER JOIN tokens
+		ON users.id = tokens.user_id
+		WHERE tokens.hash = ?
+		AND tokens.scope = ?
+		AND tokens.expiry > ?
+	`
+	args := []interface{}{tokenHash[:], tokenScope, sqliteTimeValue(time.Now())}
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return scanSQLiteUser(m.DB.QueryRowContext(ctx, query, args...))
+}
+
+// scanSQLiteUser() reads a user selected by GetByEmail() or GetForToken()
+func scanSQLiteUser(row *sql.Row) (*User, error) {
+	var user User
+	err := row.Scan(
+		&user.ID,
+		sqliteTime{&user.CreatedAt},
+		&user.Name,
+		&user.Email,
+		&user.Password.hash,
+		&user.Activated,
+		&user.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &user, nil
+}
+
+// SQLiteTokenModel stores token hashes in SQLite
+type SQLiteTokenModel struct {
+	DB      *sql.DB
+	Timeout time.Duration //the longest a single query may run
+}
+
+// New() generates a token for the user and stores it
+func (m SQLiteTokenModel) New(ctx context.Context, userID int64, ttl time.Duration, scope string) (*Token, error) {
+	token, err := generateToken(userID, ttl, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.Insert(ctx, token)
+	return token, err
+}
+
+// Insert() stores a token
+func (m SQLiteTokenModel) Insert(ctx context.Context, token *Token) error {
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return sqliteInsertToken(ctx, m.DB, token)
+}
+
+// sqliteInsertToken() stores a token, q is the pool or the caller's transaction
+func sqliteInsertToken(ctx context.Context, q queryer, token *Token) error {
+	query := `
+		INSERT INTO tokens (hash, user_id, expiry, scope)
+		VALUES (?, ?, ?, ?)
+	`
+	args := []interface{}{token.Hash, token.UserID, sqliteTimeValue(token.Expiry), token.Scope}
+
+	_, err := q.ExecContext(ctx, query, args...)
+	return err
+}
+
+// DeleteAllForUser() removes every token of the scope issued to the user
+func (m SQLiteTokenModel) DeleteAllForUser(ctx context.Context, scope string, userID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, `DELETE FROM tokens WHERE scope = ? AND user_id = ?`, scope, userID)
+	return err
+}
+
+// SQLitePermissionModel stores the permissions granted to users in SQLite
+type SQLitePermissionModel struct {
+	DB      *sql.DB
+	Timeout time.Duration //the longest a single query may run
+}
+
+// GetAllForUser() returns the permission codes granted to the user
+func (m SQLitePermissionModel) GetAllForUser(ctx context.Context, userID int64) (Permissions, error) {
+	query := `
+		SELECT permissions.code
+		FROM permissions
+		INNER JOIN users_permissions ON users_permissions.permission_id = permissions.id
+		WHERE users_permissions.user_id = ?
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var permissions Permissions
+	for rows.Next() {
+		var permission string
+		if err := rows.Scan(&permission); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, permission)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return permissions, nil
+}
+
+// AddForUser() grants the permission codes to the user, codes already granted are left alone
+func (m SQLitePermissionModel) AddForUser(ctx context.Context, userID int64, codes ...string) error {
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return sqliteAddPermissions(ctx, m.DB, userID, codes)
+}
+
+// sqliteAddPermissions() grants the permission codes to the user, q is the pool or the caller's transaction
+func sqliteAddPermissions(ctx context.Context, q queryer, userID int64, codes []string) error {
+	if len(codes) == 0 {
+		return nil
+	}
+
+	query := fmt.Sprintf(`
+		INSERT INTO users_permissions
+		SELECT ?, permissions.id FROM permissions WHERE permissions.code IN (%s)
+		ON CONFLICT DO NOTHING
+	`, sqlitePlaceholders(len(codes)))
+	args := []interface{}{userID}
+	for _, code := range codes {
+		args = append(args, code)
+	}
+
+	_, err := q.ExecContext(ctx, query, args...)
+	return err
+}
+
+// SQLiteHealthModel checks the SQLite database for the readiness check
+type SQLiteHealthModel struct {
+	DB *sql.DB
+}
+
+// Ping() checks that the database file can still be reached
+func (m SQLiteHealthModel) Ping(ctx context.Context) error {
+	return m.DB.PingContext(ctx)
+}
+
+// MigrationVersion() returns the latest applied migration, ErrRecordNotFound means nothing has been migrated yet
+func (m SQLiteHealthModel) MigrationVersion(ctx context.Context) (*MigrationStatus, error) {
+	query := `
+		SELECT version, dirty
+		FROM schema_migrations
+		ORDER BY version DESC
+		LIMIT 1
+	`
+
+	var status MigrationStatus
+	err := m.DB.QueryRowContext(ctx, query).Scan(&status.Version, &status.Dirty)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		case strings.Contains(err.Error(), "no such table"):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &status, nil
+}
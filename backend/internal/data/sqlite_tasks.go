@@ -0,0 +1,607 @@
+// File: todoApi/backend/internal/data/sqlite_tasks.go
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SQLiteTaskModel stores tasks in SQLite, every method is scoped to the owner the same way as TaskModel
+// the numbered ?NNN parameters let a query use the same argument more than once
+type SQLiteTaskModel struct {
+	DB      *sql.DB
+	Timeout time.Duration //the longest a single query may run
+}
+
+// sqliteTaskColumns selects a task_list row in the order read by scanSQLiteTask()
+// tag names cannot contain a comma so they are joined into a single column
+const sqliteTaskColumns = `task_list.id, list_id, parent_id, COALESCE(user_id, 0), created_at, title, description, completed, priority, due_at, remind_at,
+		COALESCE((
+			SELECT group_concat(name, ',') FROM (
+				SELECT tags.name FROM task_tags
+				JOIN tags ON tags.id = task_tags.tag_id
+				WHERE task_tags.task_id = task_list.id
+				ORDER BY tags.name
+			)
+		), ''),
+		version`
+
+// sqliteOwner matches the tasks, lists or tags of the user bound to ?NNN, see AnyUser
+const sqliteOwner = `(?%[1]d = -1 OR user_id IS NULLIF(?%[1]d, 0))`
+
+// scanSQLiteTask() reads the columns selected by sqliteTaskColumns
+func scanSQLiteTask(scanner interface{ Scan(...interface{}) error }) (*Task, error) {
+	var task Task
+	var tags string
+	err := scanner.Scan(
+		&task.ID,
+		&task.ListID,
+		&task.ParentID,
+		&task.UserID,
+		sqliteTime{&task.CreatedAt},
+		&task.Title,
+		&task.Descritpion,
+		&task.Completed,
+		&task.Priority,
+		sqliteTime{&task.DueAt},
+		sqliteTime{&task.RemindAt},
+		&tags,
+		&task.Version,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	task.Tags = []string{}
+	if tags != "" {
+		task.Tags = strings.Split(tags, ",")
+	}
+	return &task, nil
+}
+
+// scanSQLiteTasks() reads every row and closes rows
+func scanSQLiteTasks(rows *sql.Rows) ([]*Task, error) {
+	defer rows.Close()
+
+	tasks := []*Task{}
+	for rows.Next() {
+		task, err := scanSQLiteTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// Insert() creates a new task along with its tags
+func (m SQLiteTaskModel) Insert(ctx context.Context, task *Task) error {
+	query := `
+		INSERT INTO task_list (created_at, user_id, list_id, parent_id, title, description, completed, priority, due_at, remind_at)
+		VALUES (?, NULLIF(?, 0), ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id, created_at, completed, version
+	`
+	args := []interface{}{sqliteTimeValue(time.Now()), task.UserID, task.ListID, task.ParentID, task.Title, task.Descritpion, task.Completed, task.Priority, sqliteNullTime(task.DueAt), sqliteNullTime(task.RemindAt)}
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&task.ID, sqliteTime{&task.CreatedAt}, &task.Completed, &task.Version)
+	if err != nil {
+		return err
+	}
+
+	task.Tags = sortedTags(task.Tags)
+	err = sqliteSetTaskTags(ctx, tx, task.ID, task.UserID, task.Tags)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Get() returns a task owned by the user
+func (m SQLiteTaskModel) Get(ctx context.Context, id int64, userID int64) (*Task, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM task_list
+		WHERE id = ?1
+		AND %s
+	`, sqliteTaskColumns, fmt.Sprintf(sqliteOwner, 2))
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	task, err := scanSQLiteTask(m.DB.QueryRowContext(ctx, query, id, userID))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return task, nil
+}
+
+// Update() edits a task and replaces its tags, using the version for optimistic locking
+// when the update completes the task its incomplete subtasks are handled as completion says, in the same transaction
+// a move under another parent is checked in the transaction too, see TaskModel.Update()
+func (m SQLiteTaskModel) Update(ctx context.Context, task *Task, completion SubtaskCompletion) error {
+	query := `
+		UPDATE task_list
+		SET list_id = ?, parent_id = ?, title = ?, description = ?, completed = ?, priority = ?, due_at = ?, remind_at = ?, version = version + 1
+		WHERE id = ?
+		AND version = ?
+		AND user_id IS NULLIF(?, 0)
+		RETURNING version
+	`
+	args := []interface{}{task.ListID, task.ParentID, task.Title, task.Descritpion, task.Completed, task.Priority, sqliteNullTime(task.DueAt), sqliteNullTime(task.RemindAt), task.ID, task.Version, task.UserID}
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	//the transaction holds the write lock from the start, so neither the row nor the hierarchy can change between the checks and the update
+	var wasCompleted bool
+	var storedParentID *int64
+	err = tx.QueryRowContext(ctx, `
+		SELECT completed, parent_id FROM task_list
+		WHERE id = ?1
+		AND version = ?2
+		AND user_id IS NULLIF(?3, 0)
+	`, task.ID, task.Version, task.UserID).Scan(&wasCompleted, &storedParentID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	if task.moving(storedParentID) {
+		err = checkMove(ctx, tx, task, sqliteAncestors, sqliteSubtreeHeight)
+		if err != nil {
+			return err
+		}
+	}
+
+	completing := task.Completed && !wasCompleted
+	if completing && completion == SubtasksBlock {
+		incomplete, err := sqliteIncompleteSubtasks(ctx, tx, task.ID)
+		if err != nil {
+			return err
+		}
+		if incomplete > 0 {
+			return ErrIncompleteSubtasks
+		}
+	}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&task.Version)
+	if err != nil {
+		return err
+	}
+
+	task.Tags = sortedTags(task.Tags)
+	err = sqliteSetTaskTags(ctx, tx, task.ID, task.UserID, task.Tags)
+	if err != nil {
+		return err
+	}
+
+	if completing && completion == SubtasksCascade {
+		err = sqliteCompleteSubtasks(ctx, tx, task.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Delete() removes a task owned by the user, its subtasks go with it
+func (m SQLiteTaskModel) Delete(ctx context.Context, id int64, userID int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := fmt.Sprintf(`
+		DELETE FROM task_list
+		WHERE id = ?1
+		AND %s
+	`, fmt.Sprintf(sqliteOwner, 2))
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// sqliteMatch() turns a plainto_tsquery style query into an FTS5 query on column, every word has to appear
+// the words are quoted so nothing the user types is read as FTS5 syntax, "" is returned when the query has no words
+func sqliteMatch(column, query string) string {
+	var terms []string
+	for _, word := range textWords(query) {
+		terms = append(terms, fmt.Sprintf(`%s : "%s"`, column, strings.ReplaceAll(word, `"`, `""`)))
+	}
+	return strings.Join(terms, " AND ")
+}
+
+// GetAll() returns a page of the user's tasks meeting every condition of the filter, see TaskModel.GetAll()
+// title and description are searched through the task_search FTS5 table in place of to_tsvector() and plainto_tsquery()
+func (m SQLiteTaskModel) GetAll(ctx context.Context, userID int64, filter TaskFilter, filters Filters) ([]*Task, Metadata, error) {
+	b := &whereBuilder{sqlite: true}
+	where := b.where(userID, filter)
+	args := append([]interface{}{}, b.args...)
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	//a cursor narrows the rows down to those after it, orderBy() spells out where NULLs go as SQLite puts them first
+	page, err := filters.pageQuery()
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	pageWhere := where
+	if page.cursor != nil {
+		pageWhere += "\n\t\tAND " + keysetCondition(page.keys, page.cursor.Values, b.bind)
+	}
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM task_list
+		%s
+		ORDER BY %s
+		LIMIT %s OFFSET %s
+	`, sqliteTaskColumns, pageWhere, orderBy(page.keys), b.bind(page.limit), b.bind(page.offset))
+
+	rows, err := m.DB.QueryContext(ctx, query, b.args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	tasks, err := scanSQLiteTasks(rows)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	//counting every match and the tags across them, not just this page, unless the client can do without
+	var total *int
+	tagCounts := map[string]int{}
+	if filters.IncludeTotal {
+		var count int
+		err = m.DB.QueryRowContext(ctx, fmt.Sprintf(`SELECT COUNT(*) FROM task_list %s`, where), args...).Scan(&count)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		total = &count
+
+		if count > 0 {
+			tagCounts, err = m.tagCounts(ctx, where, args)
+			if err != nil {
+				return nil, Metadata{}, err
+			}
+		}
+	}
+
+	tasks, metadata := filters.finishPage(page, tasks, total)
+	if len(tagCounts) > 0 {
+		metadata.TagCounts = tagCounts
+	}
+	return tasks, metadata, nil
+}
+
+// withColumns reads the columns of scanSQLiteTask() followed by extra ones
+type withColumns struct {
+	scanner interface{ Scan(...interface{}) error }
+	extra   []interface{}
+}
+
+func (s withColumns) Scan(dest ...interface{}) error {
+	return s.scanner.Scan(append(dest, s.extra...)...)
+}
+
+// Search() returns a page of the user's tasks matching a search box query, see TaskModel.Search()
+// task_search does no stemming so only the simple language is supported, bm25() weighs the title ten times the description
+func (m SQLiteTaskModel) Search(ctx context.Context, userID int64, query string, language string, filters Filters) ([]*TaskSearchHit, Metadata, error) {
+	if language != "simple" {
+		return nil, Metadata{}, ErrUnsupportedLanguage
+	}
+
+	b := &whereBuilder{sqlite: true}
+	match := b.bind(parseSearchQuery(query).fts5())
+	where := b.where(userID, nil)
+	sqlQuery := fmt.Sprintf(`
+		WITH hits AS (
+			SELECT rowid AS id, -bm25(task_search, 10.0, 1.0) AS rank,
+				highlight(task_search, 0, '%[1]s', '%[2]s') AS title_headline,
+				snippet(task_search, 1, '%[1]s', '%[2]s', '…', 35) AS description_headline
+			FROM task_search
+			WHERE task_search MATCH %[3]s
+		)
+		SELECT %[4]s, hits.rank, hits.title_headline, hits.description_headline, count(*) OVER()
+		FROM task_list
+		JOIN hits ON hits.id = task_list.id
+		%[5]s
+		ORDER BY hits.rank DESC, task_list.id
+		LIMIT %[6]s OFFSET %[7]s
+	`, highlightStart, highlightStop, match, sqliteTaskColumns, where, b.bind(filters.limit()), b.bind(filters.offSet()))
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, sqlQuery, b.args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	hits := []*TaskSearchHit{}
+	for rows.Next() {
+		hit := &TaskSearchHit{}
+		hit.Task, err = scanSQLiteTask(withColumns{rows, []interface{}{&hit.Rank, &hit.Highlights.Title, &hit.Highlights.Description, &totalRecords}})
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		hit.Highlights.Title = escapeHeadline(hit.Highlights.Title)
+		hit.Highlights.Description = escapeHeadline(hit.Highlights.Description)
+		hits = append(hits, hit)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+	return hits, calculateMetaData(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// tagCounts() returns how many of the tasks matching the where clause carry each tag
+func (m SQLiteTaskModel) tagCounts(ctx context.Context, where string, args []interface{}) (map[string]int, error) {
+	query := fmt.Sprintf(`
+		SELECT tags.name, COUNT(*)
+		FROM task_tags
+		JOIN tags ON tags.id = task_tags.tag_id
+		WHERE task_tags.task_id IN (SELECT id FROM task_list %s)
+		GROUP BY tags.name
+	`, where)
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var name string
+		var count int
+		if err := rows.Scan(&name, &count); err != nil {
+			return nil, err
+		}
+		counts[name] = count
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// Ancestors() returns the ids from the task up to its top level task, see TaskModel.Ancestors()
+func (m SQLiteTaskModel) Ancestors(ctx context.Context, id int64) ([]int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return sqliteAncestors(ctx, m.DB, id)
+}
+
+// sqliteAncestors() is Ancestors() on the pool or the caller's transaction
+func sqliteAncestors(ctx context.Context, q queryer, id int64) ([]int64, error) {
+	query := `
+		WITH RECURSIVE chain AS (
+			SELECT id, parent_id, 1 AS depth
+			FROM task_list
+			WHERE id = ?1
+			UNION ALL
+			SELECT task_list.id, task_list.parent_id, chain.depth + 1
+			FROM task_list
+			JOIN chain ON task_list.id = chain.parent_id
+			WHERE chain.depth < ?2
+		)
+		SELECT id FROM chain ORDER BY depth
+	`
+
+	rows, err := q.QueryContext(ctx, query, id, maxHierarchyWalk)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var ancestor int64
+		if err := rows.Scan(&ancestor); err != nil {
+			return nil, err
+		}
+		ids = append(ids, ancestor)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, ErrRecordNotFound
+	}
+	return ids, nil
+}
+
+// SubtreeHeight() returns the number of levels in the task's subtree, see TaskModel.SubtreeHeight()
+func (m SQLiteTaskModel) SubtreeHeight(ctx context.Context, id int64) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return sqliteSubtreeHeight(ctx, m.DB, id)
+}
+
+// sqliteSubtreeHeight() is SubtreeHeight() on the pool or the caller's transaction
+func sqliteSubtreeHeight(ctx context.Context, q queryer, id int64) (int, error) {
+	query := `
+		WITH RECURSIVE tree AS (
+			SELECT id, 1 AS depth
+			FROM task_list
+			WHERE id = ?1
+			UNION ALL
+			SELECT task_list.id, tree.depth + 1
+			FROM task_list
+			JOIN tree ON task_list.parent_id = tree.id
+			WHERE tree.depth < ?2
+		)
+		SELECT COALESCE(MAX(depth), 0) FROM tree
+	`
+
+	var height int
+	err := q.QueryRowContext(ctx, query, id, maxHierarchyWalk).Scan(&height)
+	return height, err
+}
+
+// sqliteIncompleteSubtasks() counts the incomplete tasks anywhere below the task
+func sqliteIncompleteSubtasks(ctx context.Context, tx *sql.Tx, id int64) (int, error) {
+	query := `
+		WITH RECURSIVE tree AS (
+			SELECT id, completed, 0 AS depth
+			FROM task_list
+			WHERE parent_id = ?1
+			UNION ALL
+			SELECT task_list.id, task_list.completed, tree.depth + 1
+			FROM task_list
+			JOIN tree ON task_list.parent_id = tree.id
+			WHERE tree.depth < ?2
+		)
+		SELECT COUNT(*) FROM tree WHERE completed = FALSE
+	`
+
+	var count int
+	err := tx.QueryRowContext(ctx, query, id, maxHierarchyWalk).Scan(&count)
+	return count, err
+}
+
+// sqliteCompleteSubtasks() marks every task below the task as completed
+func sqliteCompleteSubtasks(ctx context.Context, tx *sql.Tx, id int64) error {
+	query := `
+		WITH RECURSIVE tree AS (
+			SELECT id, 0 AS depth
+			FROM task_list
+			WHERE parent_id = ?1
+			UNION ALL
+			SELECT task_list.id, tree.depth + 1
+			FROM task_list
+			JOIN tree ON task_list.parent_id = tree.id
+			WHERE tree.depth < ?2
+		)
+		UPDATE task_list
+		SET completed = TRUE, version = version + 1
+		WHERE id IN (SELECT id FROM tree) AND completed = FALSE
+	`
+
+	_, err := tx.ExecContext(ctx, query, id, maxHierarchyWalk)
+	return err
+}
+
+// GetSubtasks() returns the direct subtasks of a task owned by the user
+func (m SQLiteTaskModel) GetSubtasks(ctx context.Context, id int64, userID int64) ([]*Task, error) {
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM task_list
+		WHERE parent_id = ?1
+		AND %s
+		ORDER BY id ASC
+	`, sqliteTaskColumns, fmt.Sprintf(sqliteOwner, 2))
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	return scanSQLiteTasks(rows)
+}
+
+// GetTree() returns a task with its subtasks nested below it, see TaskModel.GetTree()
+func (m SQLiteTaskModel) GetTree(ctx context.Context, id int64, userID int64) (*Task, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := fmt.Sprintf(`
+		WITH RECURSIVE tree AS (
+			SELECT id, 0 AS depth
+			FROM task_list
+			WHERE id = ?1
+			AND %[2]s
+			UNION ALL
+			SELECT task_list.id, tree.depth + 1
+			FROM task_list
+			JOIN tree ON task_list.parent_id = tree.id
+			WHERE tree.depth < ?2
+			AND %[2]s
+		)
+		SELECT %[1]s
+		FROM task_list
+		JOIN tree ON tree.id = task_list.id
+		ORDER BY tree.depth ASC, task_list.id ASC
+	`, sqliteTaskColumns, fmt.Sprintf(sqliteOwner, 3))
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, id, maxHierarchyWalk, userID)
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := scanSQLiteTasks(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(tasks) == 0 {
+		return nil, ErrRecordNotFound
+	}
+
+	byID := make(map[int64]*Task, len(tasks))
+	for _, task := range tasks {
+		byID[task.ID] = task
+		if task.ParentID == nil {
+			continue
+		}
+		if parent, ok := byID[*task.ParentID]; ok && task.ID != id {
+			parent.Subtasks = append(parent.Subtasks, task)
+		}
+	}
+	return tasks[0], nil
+}
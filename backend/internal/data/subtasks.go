@@ -0,0 +1,286 @@
+// File: todoApi/backend/internal/data/subtasks.go
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrIncompleteSubtasks = errors.New("incomplete subtasks")
+)
+
+// SubtaskCompletion says what completing a task does to its incomplete subtasks
+type SubtaskCompletion string
+
+const (
+	SubtasksBlock   SubtaskCompletion = "block"   //the task cannot be completed, ErrIncompleteSubtasks is returned
+	SubtasksCascade SubtaskCompletion = "cascade" //the subtasks are completed along with the task
+	SubtasksIgnore  SubtaskCompletion = "ignore"  //the subtasks are left as they are
+)
+
+// maxHierarchyWalk bounds the recursive queries so that a corrupted hierarchy cannot loop forever
+const maxHierarchyWalk = 100
+
+// Ancestors() returns the ids on the path from the given task up to its top level task, starting with the task itself
+func (m TaskModel) Ancestors(ctx context.Context, id int64) ([]int64, error) {
+	//creating the context
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return ancestors(ctx, m.DB, id)
+}
+
+// ancestors() is Ancestors() on the pool or the caller's transaction
+func ancestors(ctx context.Context, q queryer, id int64) ([]int64, error) {
+	query := `
+		WITH RECURSIVE chain AS (
+			SELECT id, parent_id, 1 AS depth
+			FROM task_list
+			WHERE id = $1
+			UNION ALL
+			SELECT task_list.id, task_list.parent_id, chain.depth + 1
+			FROM task_list
+			JOIN chain ON task_list.id = chain.parent_id
+			WHERE chain.depth < $2
+		)
+		SELECT id FROM chain ORDER BY depth
+	`
+
+	rows, err := q.QueryContext(ctx, query, id, maxHierarchyWalk)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var ancestor int64
+		if err := rows.Scan(&ancestor); err != nil {
+			return nil, err
+		}
+		ids = append(ids, ancestor)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, ErrRecordNotFound
+	}
+	return ids, nil
+}
+
+// SubtreeHeight() returns the number of levels in the hierarchy rooted at the given task, a task without subtasks has a height of 1
+func (m TaskModel) SubtreeHeight(ctx context.Context, id int64) (int, error) {
+	//creating the context
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return subtreeHeight(ctx, m.DB, id)
+}
+
+// subtreeHeight() is SubtreeHeight() on the pool or the caller's transaction
+func subtreeHeight(ctx context.Context, q queryer, id int64) (int, error) {
+	query := `
+		WITH RECURSIVE tree AS (
+			SELECT id, 1 AS depth
+			FROM task_list
+			WHERE id = $1
+			UNION ALL
+			SELECT task_list.id, tree.depth + 1
+			FROM task_list
+			JOIN tree ON task_list.parent_id = tree.id
+			WHERE tree.depth < $2
+		)
+		SELECT COALESCE(MAX(depth), 0) FROM tree
+	`
+
+	var height int
+	err := q.QueryRowContext(ctx, query, id, maxHierarchyWalk).Scan(&height)
+	return height, err
+}
+
+// checkMove() checks the task's new parent inside the transaction updating it, with the queries of the store it belongs to
+// a parent that has gone in the meantime is reported as an edit conflict
+func checkMove(ctx context.Context, tx *sql.Tx, task *Task,
+	ancestors func(context.Context, queryer, int64) ([]int64, error),
+	subtreeHeight func(context.Context, queryer, int64) (int, error)) error {
+	chain, err := ancestors(ctx, tx, *task.ParentID)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrRecordNotFound):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	height, err := subtreeHeight(ctx, tx, task.ID)
+	if err != nil {
+		return err
+	}
+	return parentError(task, chain, height)
+}
+
+// incompleteSubtasks() counts the subtasks at any depth below the given task that are not completed
+// the subtasks are locked until the end of the transaction so none of them can be reopened before the task is completed
+func incompleteSubtasks(ctx context.Context, tx *sql.Tx, id int64) (int, error) {
+	query := `
+		WITH RECURSIVE tree AS (
+			SELECT id, 0 AS depth
+			FROM task_list
+			WHERE parent_id = $1
+			UNION ALL
+			SELECT task_list.id, tree.depth + 1
+			FROM task_list
+			JOIN tree ON task_list.parent_id = tree.id
+			WHERE tree.depth < $2
+		)
+		SELECT COUNT(*) FROM (
+			SELECT completed FROM task_list
+			WHERE id IN (SELECT id FROM tree)
+			FOR UPDATE
+		) AS subtasks
+		WHERE completed = FALSE
+	`
+
+	var count int
+	err := tx.QueryRowContext(ctx, query, id, maxHierarchyWalk).Scan(&count)
+	return count, err
+}
+
+// completeSubtasks() marks every subtask at any depth below the given task as completed
+func completeSubtasks(ctx context.Context, tx *sql.Tx, id int64) error {
+	query := `
+		WITH RECURSIVE tree AS (
+			SELECT id, 0 AS depth
+			FROM task_list
+			WHERE parent_id = $1
+			UNION ALL
+			SELECT task_list.id, tree.depth + 1
+			FROM task_list
+			JOIN tree ON task_list.parent_id = tree.id
+			WHERE tree.depth < $2
+		)
+		UPDATE task_list
+		SET completed = TRUE, version = version + 1
+		WHERE id IN (SELECT id FROM tree) AND completed = FALSE
+	`
+
+	_, err := tx.ExecContext(ctx, query, id, maxHierarchyWalk)
+	return err
+}
+
+// GetSubtasks() returns the direct subtasks of the given task that are owned by the user sorted by id
+func (m TaskModel) GetSubtasks(ctx context.Context, id int64, userID int64) ([]*Task, error) {
+	query := fmt.Sprintf(`
+		SELECT id, list_id, parent_id, COALESCE(user_id, 0), created_at, title, description, completed, priority, due_at, remind_at, %s, version
+		FROM task_list
+		WHERE parent_id = $1
+		AND ($2::bigint = -1 OR user_id IS NOT DISTINCT FROM NULLIF($2::bigint, 0))
+		ORDER BY id ASC
+	`, tagsColumn)
+
+	//creating the context
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	return scanTasks(rows)
+}
+
+// GetTree() retrieves a task owned by the user together with all of its subtasks nested under it
+func (m TaskModel) GetTree(ctx context.Context, id int64, userID int64) (*Task, error) {
+	//Ensure that there is a valid id
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	//the task is returned first followed by its subtasks level by level
+	query := fmt.Sprintf(`
+		WITH RECURSIVE tree AS (
+			SELECT id, 0 AS depth
+			FROM task_list
+			WHERE id = $1
+			AND ($3::bigint = -1 OR user_id IS NOT DISTINCT FROM NULLIF($3::bigint, 0))
+			UNION ALL
+			SELECT task_list.id, tree.depth + 1
+			FROM task_list
+			JOIN tree ON task_list.parent_id = tree.id
+			WHERE tree.depth < $2
+			AND ($3::bigint = -1 OR task_list.user_id IS NOT DISTINCT FROM NULLIF($3::bigint, 0))
+		)
+		SELECT task_list.id, list_id, parent_id, COALESCE(user_id, 0), created_at, title, description, completed, priority, due_at, remind_at, %s, version
+		FROM task_list
+		JOIN tree ON tree.id = task_list.id
+		ORDER BY tree.depth ASC, task_list.id ASC
+	`, tagsColumn)
+
+	//creating the context
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, id, maxHierarchyWalk, userID)
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := scanTasks(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(tasks) == 0 {
+		return nil, ErrRecordNotFound
+	}
+
+	//parents always come before their subtasks so each one can be attached as it is seen
+	byID := make(map[int64]*Task, len(tasks))
+	for _, task := range tasks {
+		byID[task.ID] = task
+		if task.ParentID == nil {
+			continue
+		}
+		if parent, ok := byID[*task.ParentID]; ok && task.ID != id {
+			parent.Subtasks = append(parent.Subtasks, task)
+		}
+	}
+	return tasks[0], nil
+}
+
+// scanTasks() reads every row of a task query and closes the result set
+func scanTasks(rows *sql.Rows) ([]*Task, error) {
+	defer rows.Close()
+
+	tasks := []*Task{}
+	for rows.Next() {
+		var task Task
+		err := rows.Scan(
+			&task.ID,
+			&task.ListID,
+			&task.ParentID,
+			&task.UserID,
+			&task.CreatedAt,
+			&task.Title,
+			&task.Descritpion,
+			&task.Completed,
+			&task.Priority,
+			&task.DueAt,
+			&task.RemindAt,
+			pq.Array(&task.Tags),
+			&task.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, &task)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
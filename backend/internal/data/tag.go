@@ -0,0 +1,257 @@
+// File: todoApi/backend/internal/data/tag.go
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"todo.michaelgomez.net/internal/validator"
+)
+
+var (
+	ErrDuplicateTag = errors.New("duplicate tag")
+)
+
+// tag struct supports the information for a label that can be attached to many tasks
+// every user has their own tags, the names only have to be unique per user
+type Tag struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"-"`
+	CreatedAt time.Time `json:"-"`
+	Name      string    `json:"name"`
+	TaskCount int       `json:"task_count"`
+	Version   int32     `json:"version"`
+}
+
+// ValidateTagName() checks a single tag name, key is the field the error is reported against
+func ValidateTagName(v *validator.Validator, key string, name string) {
+	v.Check(name != "", key, "must be provided")
+	v.Check(len(name) <= 50, key, "must not be more than 50 bytes long")
+	//commas are reserved for the tag=work,urgent filter
+	v.Check(!strings.Contains(name, ","), key, "must not contain a comma")
+}
+
+// sortedTags() returns a sorted copy of a task's tag names, the order every read returns them in
+func sortedTags(names []string) []string {
+	sorted := append([]string{}, names...)
+	sort.Strings(sorted)
+	return sorted
+}
+
+func ValidateTag(v *validator.Validator, tag *Tag) {
+	ValidateTagName(v, "name", tag.Name)
+}
+
+type TagModel struct {
+	DB      *sql.DB
+	Timeout time.Duration //the longest a single query may run
+}
+
+// Insert() allows us to create a new tag
+func (m TagModel) Insert(ctx context.Context, tag *Tag) error {
+	query := `
+		INSERT INTO tags (user_id, name)
+		VALUES (NULLIF($1::bigint, 0), $2)
+		RETURNING id, created_at, version
+	`
+
+	//creating the context
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	//Cleaning up to prevent memory leaks
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, tag.UserID, tag.Name).Scan(&tag.ID, &tag.CreatedAt, &tag.Version)
+	if err != nil {
+		switch {
+		case isUniqueViolation(err):
+			return ErrDuplicateTag
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// Get() allows us to retrieve a specific tag owned by the user
+func (m TagModel) Get(ctx context.Context, id int64, userID int64) (*Tag, error) {
+	//Ensure that there is a valid id
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, COALESCE(user_id, 0), created_at, name,
+		(SELECT COUNT(*) FROM task_tags WHERE tag_id = tags.id),
+		version
+		FROM tags
+		WHERE id = $1
+		AND ($2::bigint = -1 OR user_id IS NOT DISTINCT FROM NULLIF($2::bigint, 0))
+	`
+
+	var tag Tag
+
+	//Creating the context
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	//Cleaning up to prevent memory leaks
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id, userID).Scan(
+		&tag.ID,
+		&tag.UserID,
+		&tag.CreatedAt,
+		&tag.Name,
+		&tag.TaskCount,
+		&tag.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &tag, nil
+}
+
+// Update() renames a tag, optimistic locking (version number)
+func (m TagModel) Update(ctx context.Context, tag *Tag) error {
+	query := `
+		UPDATE tags
+		SET name = $1, version = version + 1
+		WHERE id = $2
+		AND version = $3
+		AND user_id IS NOT DISTINCT FROM NULLIF($4::bigint, 0)
+		RETURNING version
+	`
+	args := []interface{}{tag.Name, tag.ID, tag.Version, tag.UserID}
+
+	//Creating the context
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	//Cleaning up to prevent memory leaks
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&tag.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		case isUniqueViolation(err):
+			return ErrDuplicateTag
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete() removes a specific tag owned by the user, it is detached from every task that carried it
+func (m TagModel) Delete(ctx context.Context, id int64, userID int64) error {
+	//Ensure that there is a valid id
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+	query := `
+		DELETE FROM tags
+		WHERE id = $1
+		AND ($2::bigint = -1 OR user_id IS NOT DISTINCT FROM NULLIF($2::bigint, 0))
+	`
+
+	//creating the context
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	//clearing up to prevent memory leaks
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// the GetAll() method returns the user's tags sorted by name along with how many tasks use each
+func (m TagModel) GetAll(ctx context.Context, userID int64) ([]*Tag, error) {
+	query := `
+		SELECT id, COALESCE(user_id, 0), created_at, name,
+		(SELECT COUNT(*) FROM task_tags WHERE tag_id = tags.id),
+		version
+		FROM tags
+		WHERE ($1::bigint = -1 OR user_id IS NOT DISTINCT FROM NULLIF($1::bigint, 0))
+		ORDER BY name ASC, id ASC
+	`
+
+	//creating the time out context
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []*Tag{}
+	for rows.Next() {
+		var tag Tag
+		err := rows.Scan(
+			&tag.ID,
+			&tag.UserID,
+			&tag.CreatedAt,
+			&tag.Name,
+			&tag.TaskCount,
+			&tag.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, &tag)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// setTaskTags() replaces the tags attached to a task with the given names of its owner's tags
+// tags that do not exist yet are created, it runs inside the caller's transaction
+func setTaskTags(ctx context.Context, tx *sql.Tx, taskID int64, userID int64, names []string) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO tags (user_id, name)
+		SELECT NULLIF($1::bigint, 0), unnest($2::text[])
+		ON CONFLICT (COALESCE(user_id, 0), name) DO NOTHING
+	`, userID, pq.Array(names))
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM task_tags WHERE task_id = $1`, taskID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO task_tags (task_id, tag_id)
+		SELECT $1, id FROM tags
+		WHERE name = ANY($2)
+		AND user_id IS NOT DISTINCT FROM NULLIF($3::bigint, 0)
+	`, taskID, pq.Array(names), userID)
+	return err
+}
+
+// isUniqueViolation() reports whether err is a postgres unique constraint violation
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
@@ -8,19 +8,31 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/lib/pq"
 	"todo.michaelgomez.net/internal/validator"
 )
 
 // task struct supports the infromation for the todo task
 type Task struct {
-	ID          int64     `json:"id"`
-	CreatedAt   time.Time `json:"-"`
-	Title       string    `json:"title"`
-	Descritpion string    `json:"description"`
-	Completed   bool      `json:"completed"`
-	Version     int32     `json:"version"`
+	ID          int64      `json:"id"`
+	ListID      *int64     `json:"list_id,omitempty"`
+	ParentID    *int64     `json:"parent_id,omitempty"`
+	UserID      int64      `json:"-"`
+	CreatedAt   time.Time  `json:"-"`
+	Title       string     `json:"title"`
+	Descritpion string     `json:"description"`
+	Completed   bool       `json:"completed"`
+	Priority    Priority   `json:"priority"`
+	DueAt       *time.Time `json:"due_at,omitempty"`
+	RemindAt    *time.Time `json:"remind_at,omitempty"`
+	Tags        []string   `json:"tags"`
+	Version     int32      `json:"version"`
+	Subtasks    []*Task    `json:"subtasks,omitempty"`
 }
 
+// TaskSortColumns are the columns a task listing can be sorted by, in either direction
+var TaskSortColumns = []string{"id", "title", "description", "completed", "priority", "due_at", "created_at"}
+
 func ValidateTask(v *validator.Validator, task *Task) {
 	//using check() method to check our validation checks
 	v.Check(task.Title != "", "title", "must be provided")
@@ -29,60 +41,154 @@ func ValidateTask(v *validator.Validator, task *Task) {
 	v.Check(task.Descritpion != "", "description", "must be provided")
 	v.Check(len(task.Descritpion) <= 250, "description", "must no be more than 250 bytes long")
 
+	v.Check(task.Priority.Valid(), "priority", "must be one of none, low, medium, high or urgent")
+
+	//a reminder only makes sense before the task is due
+	if task.DueAt != nil && task.RemindAt != nil {
+		v.Check(!task.RemindAt.After(*task.DueAt), "remind_at", "must not be after the due date")
+	}
+
+	//checking every tag name as well as the set of tags
+	for _, name := range task.Tags {
+		ValidateTagName(v, "tags", name)
+	}
+	v.Check(len(task.Tags) <= 20, "tags", "must not contain more than 20 tags")
+	v.Check(validator.Unique(task.Tags), "tags", "must not contain duplicate values")
+
 	//v.Check(task.Completed, "completed", "new task must be false")
 }
 
+// MaxTaskDepth is the number of levels a task hierarchy may have, a top level task is at depth 1
+const MaxTaskDepth = 5
+
+var (
+	ErrParentCycle = errors.New("parent is the task or one of its subtasks")
+	ErrTaskTooDeep = errors.New("subtasks nested too deep")
+)
+
+// ValidateParent() checks that the task can be placed under its parent
+// ancestors is the chain of ids from the new parent up to the top level task and height is the number of levels in the task's own subtree
+func ValidateParent(v *validator.Validator, task *Task, ancestors []int64, height int) {
+	AddParentError(v, parentError(task, ancestors, height))
+}
+
+// AddParentError() adds the validation error for ErrParentCycle or ErrTaskTooDeep, any other error is left alone
+func AddParentError(v *validator.Validator, err error) {
+	switch {
+	case errors.Is(err, ErrParentCycle):
+		v.AddError("parent_id", "must not be the task itself or one of its subtasks")
+	case errors.Is(err, ErrTaskTooDeep):
+		v.AddError("parent_id", fmt.Sprintf("subtasks must not be nested more than %d levels deep", MaxTaskDepth))
+	}
+}
+
+// parentError() returns why the task cannot be placed under its parent, or nil when it can, see ValidateParent()
+func parentError(task *Task, ancestors []int64, height int) error {
+	if task.ParentID == nil {
+		return nil
+	}
+	//a task cannot become a subtask of itself or of one of its own subtasks
+	for _, id := range ancestors {
+		if id == task.ID {
+			return ErrParentCycle
+		}
+	}
+	if len(ancestors)+height > MaxTaskDepth {
+		return ErrTaskTooDeep
+	}
+	return nil
+}
+
+// moving() reports whether the update puts the task under a parent other than storedParentID
+func (task *Task) moving(storedParentID *int64) bool {
+	return task.ParentID != nil && (storedParentID == nil || *storedParentID != *task.ParentID)
+}
+
+// taskMoveLockKey identifies the Postgres advisory lock held by an update that moves a task under another parent
+// the moves are serialized so that two of them, say A under B and B under A, cannot both pass the cycle check
+const taskMoveLockKey int64 = 7_301_946_220_119
+
+// AnyUser can be passed as the userID of TaskModel methods to reach every user's tasks
+const AnyUser int64 = -1
+
+// TaskModel scopes every task to its owner, a userID of 0 stands for the anonymous user and matches tasks without an owner
 type TaskModel struct {
-	DB *sql.DB
+	DB      *sql.DB
+	Timeout time.Duration //the longest a single query may run
 }
 
 // Insert() allows us to create a new task
-func (m TaskModel) Insert(task *Task) error {
+func (m TaskModel) Insert(ctx context.Context, task *Task) error {
 	query := `
-		INSERT INTO task_list (title, description, completed)
-		VALUES ($1, $2, $3)
+		INSERT INTO task_list (user_id, list_id, parent_id, title, description, completed, priority, due_at, remind_at)
+		VALUES (NULLIF($1::bigint, 0), $2, $3, $4, $5, $6, $7, $8, $9)
 		RETURNING id, created_at, completed, version
 	`
 
 	//creating the context
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
 	//Cleaning up to prevent memory leaks
 	defer cancel()
 
 	//collect the date field into a slice
-	args := []interface{}{task.Title, task.Descritpion, task.Completed}
+	args := []interface{}{task.UserID, task.ListID, task.ParentID, task.Title, task.Descritpion, task.Completed, task.Priority, task.DueAt, task.RemindAt}
+
+	//the task and its tags are written together so a failure leaves neither behind
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&task.ID, &task.CreatedAt, &task.Completed, &task.Version)
+	if err != nil {
+		return err
+	}
 
-	return m.DB.QueryRowContext(ctx, query, args...).Scan(&task.ID, &task.CreatedAt, &task.Completed, &task.Version)
+	task.Tags = sortedTags(task.Tags)
+	err = setTaskTags(ctx, tx, task.ID, task.UserID, task.Tags)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-// Get() allows us to retrieve a specific task
-func (m TaskModel) Get(id int64) (*Task, error) {
+// Get() allows us to retrieve a specific task owned by the user
+func (m TaskModel) Get(ctx context.Context, id int64, userID int64) (*Task, error) {
 	//Ensure that there is a valid id
 	if id < 1 {
 		return nil, ErrRecordNotFound
 	}
 
 	//Construct our query with the given id
-	query := `
-		SELECT id, created_at, title, description, completed, version
+	query := fmt.Sprintf(`
+		SELECT id, list_id, parent_id, COALESCE(user_id, 0), created_at, title, description, completed, priority, due_at, remind_at, %s, version
 		FROM task_list
 		WHERE id = $1
-	`
+		AND ($2::bigint = -1 OR user_id IS NOT DISTINCT FROM NULLIF($2::bigint, 0))
+	`, tagsColumn)
 
 	//Declaring the Task varaible to hold the returned data
 	var task Task
 
 	//Creating the context
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
 	//Cleaning up to prevent memory leaks
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+	err := m.DB.QueryRowContext(ctx, query, id, userID).Scan(
 		&task.ID,
+		&task.ListID,
+		&task.ParentID,
+		&task.UserID,
 		&task.CreatedAt,
 		&task.Title,
 		&task.Descritpion,
 		&task.Completed,
+		&task.Priority,
+		&task.DueAt,
+		&task.RemindAt,
+		pq.Array(&task.Tags),
 		&task.Version,
 	)
 
@@ -101,24 +207,34 @@ func (m TaskModel) Get(id int64) (*Task, error) {
 
 // Update() allows us to edit/alter a specific task
 // Optimistic locking (version number)
-func (m TaskModel) Update(task *Task) error {
+// when the update completes the task its incomplete subtasks are handled as completion says, in the same transaction
+// a move under another parent is checked for cycles and depth in the transaction too, returning ErrParentCycle or ErrTaskTooDeep
+func (m TaskModel) Update(ctx context.Context, task *Task, completion SubtaskCompletion) error {
 	//create a query
 	query := `
 		UPDATE task_list
-		SET title = $1, description = $2, completed = $3, version = version + 1
-		WHERE id = $4
-		AND version = $5
+		SET list_id = $1, parent_id = $2, title = $3, description = $4, completed = $5, priority = $6, due_at = $7, remind_at = $8, version = version + 1
+		WHERE id = $9
+		AND version = $10
+		AND user_id IS NOT DISTINCT FROM NULLIF($11::bigint, 0)
 		RETURNING version
 	`
-	args := []interface{}{task.Title, task.Descritpion, task.Completed, task.ID, task.Version}
+	args := []interface{}{task.ListID, task.ParentID, task.Title, task.Descritpion, task.Completed, task.Priority, task.DueAt, task.RemindAt, task.ID, task.Version, task.UserID}
 
 	//Creating the context
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
 	//Cleaning up to prevent memory leaks
 	defer cancel()
 
-	//Check for edit conflicts
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&task.Version)
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	//the move lock is taken before any row lock, a parent changed in the meantime shows up as a new version below
+	var storedParentID *int64
+	err = tx.QueryRowContext(ctx, `SELECT parent_id FROM task_list WHERE id = $1`, task.ID).Scan(&storedParentID)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -127,12 +243,73 @@ func (m TaskModel) Update(task *Task) error {
 			return err
 		}
 	}
-	return nil
+	moving := task.moving(storedParentID)
+	if moving {
+		_, err = tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, taskMoveLockKey)
+		if err != nil {
+			return err
+		}
+	}
+
+	//locking the row and checking for edit conflicts before looking at the subtasks
+	var wasCompleted bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT completed FROM task_list
+		WHERE id = $1
+		AND version = $2
+		AND user_id IS NOT DISTINCT FROM NULLIF($3::bigint, 0)
+		FOR UPDATE
+	`, task.ID, task.Version, task.UserID).Scan(&wasCompleted)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	//the hierarchy is read after every earlier move has committed, so the check holds until this one commits
+	if moving {
+		err = checkMove(ctx, tx, task, ancestors, subtreeHeight)
+		if err != nil {
+			return err
+		}
+	}
+
+	completing := task.Completed && !wasCompleted
+	if completing && completion == SubtasksBlock {
+		incomplete, err := incompleteSubtasks(ctx, tx, task.ID)
+		if err != nil {
+			return err
+		}
+		if incomplete > 0 {
+			return ErrIncompleteSubtasks
+		}
+	}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&task.Version)
+	if err != nil {
+		return err
+	}
+
+	task.Tags = sortedTags(task.Tags)
+	err = setTaskTags(ctx, tx, task.ID, task.UserID, task.Tags)
+	if err != nil {
+		return err
+	}
 
+	if completing && completion == SubtasksCascade {
+		err = completeSubtasks(ctx, tx, task.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
 }
 
-// Delete() removes a specific task
-func (m TaskModel) Delete(id int64) error {
+// Delete() removes a specific task owned by the user
+func (m TaskModel) Delete(ctx context.Context, id int64, userID int64) error {
 	//Ensure that there is a valid id
 	if id < 1 {
 		return ErrRecordNotFound
@@ -141,15 +318,16 @@ func (m TaskModel) Delete(id int64) error {
 	query := `
 		DELETE FROM task_list
 		WHERE id = $1
+		AND ($2::bigint = -1 OR user_id IS NOT DISTINCT FROM NULLIF($2::bigint, 0))
 	`
 
 	//creating the context
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
 	//clearing up to prevent memory leaks
 	defer cancel()
 
 	//Executing the query
-	result, err := m.DB.ExecContext(ctx, query, id)
+	result, err := m.DB.ExecContext(ctx, query, id, userID)
 	if err != nil {
 		return err
 	}
@@ -167,79 +345,107 @@ func (m TaskModel) Delete(id int64) error {
 	return nil
 }
 
-// the GetAll() method returns a list of all tasks sorted by id
-func (m TaskModel) GetAll(title string, description string, completed bool, filters Filters) ([]*Task, Metadata, error) {
+// the GetAll() method returns a page of the user's tasks meeting every condition of the filter
+func (m TaskModel) GetAll(ctx context.Context, userID int64, filter TaskFilter, filters Filters) ([]*Task, Metadata, error) {
+	//the filtering conditions are shared by the listing and the tag counts
+	b := &whereBuilder{}
+	where := b.where(userID, filter)
+	args := append([]interface{}{}, b.args...)
+
+	//creating the time out context
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	//a cursor narrows the rows down to those after it, the extra arguments follow the filters
+	page, err := filters.pageQuery()
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	pageWhere := where
+	if page.cursor != nil {
+		pageWhere += "\n\t\tAND " + keysetCondition(page.keys, page.cursor.Values, b.bind)
+	}
+
 	//constructing the query
 	query := fmt.Sprintf(`
-		SELECT COUNT(*) OVER(),
-		id, created_at, title, description, completed, version
+		SELECT id, list_id, parent_id, COALESCE(user_id, 0), created_at, title, description, completed, priority, due_at, remind_at, %s, version
 		FROM task_list
-		WHERE (to_tsvector('simple', title) @@ plainto_tsquery('simple', $1) OR $1 = '')
-		AND (to_tsvector('simple', description) @@ plainto_tsquery('simple', $2) OR $2 = '')
-		AND (completed = $3 OR completed = TRUE)
-		ORDER BY %s %s, id ASC
-		LIMIT $4 OFFSET $5
-	`, filters.sortColumn(), filters.sortOrder())
-
-	//creating the 3 second time out context
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-
-	//fmt.Println("Debug ! 2.5")
+		%s
+		ORDER BY %s
+		LIMIT %s OFFSET %s
+	`, tagsColumn, pageWhere, orderBy(page.keys), b.bind(page.limit), b.bind(page.offset))
 
 	//Execute the query
-	args := []interface{}{title, description, completed, filters.limit(), filters.offSet()}
-	//fmt.Println("Debug ! 2.42")
-	rows, err := m.DB.QueryContext(ctx, query, args...)
+	rows, err := m.DB.QueryContext(ctx, query, b.args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	tasks, err := scanTasks(rows)
 	if err != nil {
 		return nil, Metadata{}, err
 	}
 
-	//fmt.Println("Debug ! 2.41")
-
-	//Closing the result set
-	defer rows.Close()
-	totalRecords := 0
-
-	//Initialize an empty slice to hold the task data
-	tasks := []*Task{}
-
-	//fmt.Println("Debug ! 2.4")
-
-	//Iterate over the rows in the result set
-	for rows.Next() {
-		var task Task
-
-		//Scanning the valus from the row into the task struct
-		err := rows.Scan(
-			&totalRecords,
-			&task.ID,
-			&task.CreatedAt,
-			&task.Title,
-			&task.Descritpion,
-			&task.Completed,
-			&task.Version,
-		)
+	//counting every match and the tags across them, not just this page, unless the client can do without
+	var total *int
+	tagCounts := map[string]int{}
+	if filters.IncludeTotal {
+		var count int
+		err = m.DB.QueryRowContext(ctx, fmt.Sprintf(`SELECT COUNT(*) FROM task_list %s`, where), args...).Scan(&count)
 		if err != nil {
 			return nil, Metadata{}, err
 		}
-		//Add the task to our slice
-		tasks = append(tasks, &task)
-	}
+		total = &count
 
-	//fmt.Println("Debug ! 2.3")
+		if count > 0 {
+			tagCounts, err = m.tagCounts(ctx, where, args)
+			if err != nil {
+				return nil, Metadata{}, err
+			}
+		}
+	}
 
-	//checking for errors after looping through the result set
-	if err = rows.Err(); err != nil {
-		return nil, Metadata{}, err
+	tasks, metadata := filters.finishPage(page, tasks, total)
+	if len(tagCounts) > 0 {
+		metadata.TagCounts = tagCounts
 	}
+	return tasks, metadata, nil
+}
 
-	//fmt.Println("Debug ! 2.2")
+// tagsColumn selects the sorted tag names of a task_list row as a text array
+const tagsColumn = `ARRAY(
+			SELECT tags.name FROM task_tags
+			JOIN tags ON tags.id = task_tags.tag_id
+			WHERE task_tags.task_id = task_list.id
+			ORDER BY tags.name
+		)`
 
-	metadata := calculateMetaData(totalRecords, filters.Page, filters.PageSize)
-	//returning the slice of tasks
+// tagCounts() returns how many of the tasks matching the where clause carry each tag
+func (m TaskModel) tagCounts(ctx context.Context, where string, args []interface{}) (map[string]int, error) {
+	query := fmt.Sprintf(`
+		SELECT tags.name, COUNT(*)
+		FROM task_tags
+		JOIN tags ON tags.id = task_tags.tag_id
+		WHERE task_tags.task_id IN (SELECT id FROM task_list %s)
+		GROUP BY tags.name
+	`, where)
 
-	//fmt.Println("Debug ! 2.1")
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-	return tasks, metadata, nil
+	counts := make(map[string]int)
+	for rows.Next() {
+		var name string
+		var count int
+		if err := rows.Scan(&name, &count); err != nil {
+			return nil, err
+		}
+		counts[name] = count
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return counts, nil
 }
@@ -0,0 +1,289 @@
+// File: todoApi/backend/internal/data/task_filter.go
+package data
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Condition is one restriction on a task listing, the postgres and sqlite stores write it out as SQL and the memory store
+// checks it against each task so that every store agrees on what matches
+type Condition interface {
+	sql(b *whereBuilder) string
+	match(task *Task) bool
+}
+
+// TaskFilter is the set of conditions a task has to meet to be listed, an empty filter lists every task
+type TaskFilter []Condition
+
+// whereBuilder writes a TaskFilter out as a WHERE clause
+// values never end up in the SQL itself, they are bound to placeholders and collected in args
+type whereBuilder struct {
+	sqlite bool //"?NNN" placeholders, timestamps as text and the task_search table in place of to_tsvector()
+	args   []interface{}
+}
+
+// The bind() method adds a value to the arguments and returns its placeholder
+// the placeholders are numbered in both dialects as keysetCondition() repeats them
+func (b *whereBuilder) bind(value interface{}) string {
+	if t, ok := value.(time.Time); ok && b.sqlite {
+		value = sqliteTimeValue(t)
+	}
+	b.args = append(b.args, value)
+	if b.sqlite {
+		return fmt.Sprintf("?%d", len(b.args))
+	}
+	return fmt.Sprintf("$%d", len(b.args))
+}
+
+// The where() method returns the WHERE clause matching the tasks of userID that meet every condition of the filter
+func (b *whereBuilder) where(userID int64, filter TaskFilter) string {
+	conditions := allOf(append(TaskFilter{ownedBy(userID)}, filter...))
+	return "WHERE " + conditions.sql(b)
+}
+
+// The match() method reports whether the task meets every condition of the filter
+func (filter TaskFilter) match(task *Task) bool {
+	return allOf(filter).match(task)
+}
+
+// ownedBy() limits the listing to the tasks visible to userID, AnyUser sees every task
+func ownedBy(userID int64) Condition {
+	switch userID {
+	case AnyUser:
+		return allOf{}
+	case 0:
+		return isNull{column: "user_id"}
+	}
+	return comparison{column: "user_id", op: "=", value: userID}
+}
+
+// InList() matches the tasks of a list
+func InList(listID int64) Condition {
+	return comparison{column: "list_id", op: "=", value: listID}
+}
+
+// CompletedIs() matches the completed tasks or the open ones
+func CompletedIs(completed bool) Condition {
+	return comparison{column: "completed", op: "=", value: completed}
+}
+
+// TitleMatches() matches the tasks whose title holds every word of the query
+func TitleMatches(query string) Condition {
+	return textSearch{column: "title", query: query}
+}
+
+// DescriptionMatches() matches the tasks whose description holds every word of the query
+func DescriptionMatches(query string) Condition {
+	return textSearch{column: "description", query: query}
+}
+
+// DueBefore() matches the tasks due before t, tasks without a due date never match
+func DueBefore(t time.Time) Condition {
+	return comparison{column: "due_at", op: "<", value: t.UTC()}
+}
+
+// DueAfter() matches the tasks due after t, tasks without a due date never match
+func DueAfter(t time.Time) Condition {
+	return comparison{column: "due_at", op: ">", value: t.UTC()}
+}
+
+// CreatedBefore() matches the tasks created before t
+func CreatedBefore(t time.Time) Condition {
+	return comparison{column: "created_at", op: "<", value: t.UTC()}
+}
+
+// CreatedAfter() matches the tasks created after t
+func CreatedAfter(t time.Time) Condition {
+	return comparison{column: "created_at", op: ">", value: t.UTC()}
+}
+
+// Overdue() matches the open tasks that were due before now
+func Overdue(now time.Time) Condition {
+	return allOf{DueBefore(now), CompletedIs(false)}
+}
+
+// PriorityIn() matches the tasks with any of the priorities
+func PriorityIn(priorities ...Priority) Condition {
+	values := make([]interface{}, len(priorities))
+	for i := range priorities {
+		values[i] = int64(priorities[i])
+	}
+	return inList{column: "priority", values: values}
+}
+
+// IDIn() matches the tasks with any of the ids
+func IDIn(ids ...int64) Condition {
+	values := make([]interface{}, len(ids))
+	for i := range ids {
+		values[i] = ids[i]
+	}
+	return inList{column: "id", values: values}
+}
+
+// TaggedWith() matches the tasks carrying any of the tags, or all of them when matchAll is set
+func TaggedWith(names []string, matchAll bool) Condition {
+	return tagged{names: names, matchAll: matchAll}
+}
+
+// Not() matches the tasks the condition does not match
+// a comparison with a missing value, such as a due date, counts as not matching so its negation matches
+func Not(condition Condition) Condition {
+	return not{condition: condition}
+}
+
+// comparison compares a column with a value, a NULL column never matches
+type comparison struct {
+	column string
+	op     string //"=", "<" or ">"
+	value  interface{}
+}
+
+func (c comparison) sql(b *whereBuilder) string {
+	return fmt.Sprintf("%s %s %s", c.column, c.op, b.bind(c.value))
+}
+
+func (c comparison) match(task *Task) bool {
+	value := columnValue(task, c.column)
+	if value == nil {
+		return false
+	}
+	result := compareValues(value, c.value)
+	switch c.op {
+	case "<":
+		return result < 0
+	case ">":
+		return result > 0
+	}
+	return result == 0
+}
+
+// isNull matches the tasks where a column has no value
+type isNull struct {
+	column string
+}
+
+func (c isNull) sql(b *whereBuilder) string {
+	return c.column + " IS NULL"
+}
+
+func (c isNull) match(task *Task) bool {
+	return columnValue(task, c.column) == nil
+}
+
+// inList matches a column against a list of values, an empty list matches nothing
+type inList struct {
+	column string
+	values []interface{}
+}
+
+func (c inList) sql(b *whereBuilder) string {
+	if len(c.values) == 0 {
+		return "FALSE"
+	}
+	placeholders := make([]string, len(c.values))
+	for i, value := range c.values {
+		placeholders[i] = b.bind(value)
+	}
+	return fmt.Sprintf("%s IN (%s)", c.column, strings.Join(placeholders, ", "))
+}
+
+func (c inList) match(task *Task) bool {
+	value := columnValue(task, c.column)
+	for _, v := range c.values {
+		if value != nil && compareValues(value, v) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// textSearch matches the tasks where a column holds every word of a plainto_tsquery() style query
+type textSearch struct {
+	column string
+	query  string
+}
+
+func (c textSearch) sql(b *whereBuilder) string {
+	if !b.sqlite {
+		return fmt.Sprintf("to_tsvector('simple', %s) @@ plainto_tsquery('simple', %s)", c.column, b.bind(c.query))
+	}
+
+	//a query without any words matches nothing, as an empty tsquery does
+	match := sqliteMatch(c.column, c.query)
+	if match == "" {
+		return "FALSE"
+	}
+	return fmt.Sprintf("id IN (SELECT rowid FROM task_search WHERE task_search MATCH %s)", b.bind(match))
+}
+
+func (c textSearch) match(task *Task) bool {
+	text, _ := columnValue(task, c.column).(string)
+	return len(textWords(c.query)) > 0 && textMatches(text, c.query)
+}
+
+// tagged matches the tasks carrying any, or all, of the tags
+type tagged struct {
+	names    []string
+	matchAll bool
+}
+
+func (c tagged) sql(b *whereBuilder) string {
+	if len(c.names) == 0 {
+		return "TRUE"
+	}
+	placeholders := make([]string, len(c.names))
+	for i, name := range c.names {
+		placeholders[i] = b.bind(name)
+	}
+	wanted := 1
+	if c.matchAll {
+		wanted = len(c.names)
+	}
+	return fmt.Sprintf(`(
+			SELECT COUNT(*) FROM task_tags
+			JOIN tags ON tags.id = task_tags.tag_id
+			WHERE task_tags.task_id = task_list.id AND tags.name IN (%s)
+		) >= %s`, strings.Join(placeholders, ", "), b.bind(int64(wanted)))
+}
+
+func (c tagged) match(task *Task) bool {
+	return len(c.names) == 0 || tagsMatch(task.Tags, c.names, c.matchAll)
+}
+
+// not matches what its condition does not, the COALESCE() turns a comparison with NULL into a mismatch first
+type not struct {
+	condition Condition
+}
+
+func (c not) sql(b *whereBuilder) string {
+	return fmt.Sprintf("NOT COALESCE(%s, FALSE)", c.condition.sql(b))
+}
+
+func (c not) match(task *Task) bool {
+	return !c.condition.match(task)
+}
+
+// allOf matches the tasks meeting every one of its conditions, an empty allOf matches every task
+type allOf []Condition
+
+func (c allOf) sql(b *whereBuilder) string {
+	if len(c) == 0 {
+		return "TRUE"
+	}
+	conditions := make([]string, len(c))
+	for i, condition := range c {
+		conditions[i] = "(" + condition.sql(b) + ")"
+	}
+	return strings.Join(conditions, "\n\t\tAND ")
+}
+
+func (c allOf) match(task *Task) bool {
+	for _, condition := range c {
+		if !condition.match(task) {
+			return false
+		}
+	}
+	return true
+}
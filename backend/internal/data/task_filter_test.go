@@ -0,0 +1,240 @@
+// File: todoApi/backend/internal/data/task_filter_test.go
+package data
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWhereBuilder(t *testing.T) {
+	due := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	created := time.Date(2024, 4, 1, 8, 30, 0, 0, time.UTC)
+	and := func(conditions ...string) string {
+		return "WHERE " + strings.Join(conditions, "\n\t\tAND ")
+	}
+
+	tests := []struct {
+		name       string
+		userID     int64
+		filter     TaskFilter
+		want       string //with "$n" placeholders, the sqlite clause is the same with "?n"
+		args       []interface{}
+		sqliteWant string        //only set when sqlite writes different SQL, also with "$n" placeholders
+		sqliteArgs []interface{} //only set when sqlite binds different values
+	}{
+		{
+			name:   "any user without a filter",
+			userID: AnyUser,
+			want:   and("(TRUE)"),
+		},
+		{
+			name:   "anonymous user owns the tasks without an owner",
+			userID: 0,
+			want:   and("(user_id IS NULL)"),
+		},
+		{
+			name:   "owner",
+			userID: 7,
+			want:   and("(user_id = $1)"),
+			args:   []interface{}{int64(7)},
+		},
+		{
+			name:   "completed unset",
+			userID: 7,
+			filter: TaskFilter{},
+			want:   and("(user_id = $1)"),
+			args:   []interface{}{int64(7)},
+		},
+		{
+			name:   "completed true",
+			userID: 7,
+			filter: TaskFilter{CompletedIs(true)},
+			want:   and("(user_id = $1)", "(completed = $2)"),
+			args:   []interface{}{int64(7), true},
+		},
+		{
+			name:   "completed false",
+			userID: 7,
+			filter: TaskFilter{CompletedIs(false)},
+			want:   and("(user_id = $1)", "(completed = $2)"),
+			args:   []interface{}{int64(7), false},
+		},
+		{
+			name:   "list",
+			userID: AnyUser,
+			filter: TaskFilter{InList(3)},
+			want:   and("(TRUE)", "(list_id = $1)"),
+			args:   []interface{}{int64(3)},
+		},
+		{
+			name:   "ids",
+			userID: 7,
+			filter: TaskFilter{IDIn(4, 5)},
+			want:   and("(user_id = $1)", "(id IN ($2, $3))"),
+			args:   []interface{}{int64(7), int64(4), int64(5)},
+		},
+		{
+			name:   "not_id",
+			userID: 7,
+			filter: TaskFilter{Not(IDIn(4, 5))},
+			want:   and("(user_id = $1)", "(NOT COALESCE(id IN ($2, $3), FALSE))"),
+			args:   []interface{}{int64(7), int64(4), int64(5)},
+		},
+		{
+			name:   "not_id without ids matches every task",
+			userID: 7,
+			filter: TaskFilter{Not(IDIn())},
+			want:   and("(user_id = $1)", "(NOT COALESCE(FALSE, FALSE))"),
+			args:   []interface{}{int64(7)},
+		},
+		{
+			name:   "not_priority",
+			userID: 7,
+			filter: TaskFilter{Not(PriorityIn(PriorityHigh, PriorityUrgent))},
+			want:   and("(user_id = $1)", "(NOT COALESCE(priority IN ($2, $3), FALSE))"),
+			args:   []interface{}{int64(7), int64(PriorityHigh), int64(PriorityUrgent)},
+		},
+		{
+			name:       "not_due_before turns a NULL due date into a mismatch first",
+			userID:     0,
+			filter:     TaskFilter{Not(DueBefore(due))},
+			want:       and("(user_id IS NULL)", "(NOT COALESCE(due_at < $1, FALSE))"),
+			args:       []interface{}{due},
+			sqliteArgs: []interface{}{sqliteTimeValue(due)},
+		},
+		{
+			name:       "overdue",
+			userID:     7,
+			filter:     TaskFilter{Overdue(due)},
+			want:       and("(user_id = $1)", "((due_at < $2)\n\t\tAND (completed = $3))"),
+			args:       []interface{}{int64(7), due, false},
+			sqliteArgs: []interface{}{int64(7), sqliteTimeValue(due), false},
+		},
+		{
+			name:       "created_after",
+			userID:     7,
+			filter:     TaskFilter{CreatedAfter(created)},
+			want:       and("(user_id = $1)", "(created_at > $2)"),
+			args:       []interface{}{int64(7), created},
+			sqliteArgs: []interface{}{int64(7), sqliteTimeValue(created)},
+		},
+		{
+			name:       "created_before",
+			userID:     7,
+			filter:     TaskFilter{CreatedBefore(created)},
+			want:       and("(user_id = $1)", "(created_at < $2)"),
+			args:       []interface{}{int64(7), created},
+			sqliteArgs: []interface{}{int64(7), sqliteTimeValue(created)},
+		},
+		{
+			name:       "not created_after",
+			userID:     7,
+			filter:     TaskFilter{Not(CreatedAfter(created))},
+			want:       and("(user_id = $1)", "(NOT COALESCE(created_at > $2, FALSE))"),
+			args:       []interface{}{int64(7), created},
+			sqliteArgs: []interface{}{int64(7), sqliteTimeValue(created)},
+		},
+		{
+			name:       "not created_before",
+			userID:     7,
+			filter:     TaskFilter{Not(CreatedBefore(created))},
+			want:       and("(user_id = $1)", "(NOT COALESCE(created_at < $2, FALSE))"),
+			args:       []interface{}{int64(7), created},
+			sqliteArgs: []interface{}{int64(7), sqliteTimeValue(created)},
+		},
+		{
+			name:       "created between",
+			userID:     7,
+			filter:     TaskFilter{CreatedAfter(created), CreatedBefore(due)},
+			want:       and("(user_id = $1)", "(created_at > $2)", "(created_at < $3)"),
+			args:       []interface{}{int64(7), created, due},
+			sqliteArgs: []interface{}{int64(7), sqliteTimeValue(created), sqliteTimeValue(due)},
+		},
+		{
+			name:   "tagged with any",
+			userID: 7,
+			filter: TaskFilter{TaggedWith([]string{"home", "work"}, false)},
+			want: and("(user_id = $1)", `((
+			SELECT COUNT(*) FROM task_tags
+			JOIN tags ON tags.id = task_tags.tag_id
+			WHERE task_tags.task_id = task_list.id AND tags.name IN ($2, $3)
+		) >= $4)`),
+			args: []interface{}{int64(7), "home", "work", int64(1)},
+		},
+		{
+			name:   "tagged with all",
+			userID: 7,
+			filter: TaskFilter{TaggedWith([]string{"home", "work"}, true)},
+			want: and("(user_id = $1)", `((
+			SELECT COUNT(*) FROM task_tags
+			JOIN tags ON tags.id = task_tags.tag_id
+			WHERE task_tags.task_id = task_list.id AND tags.name IN ($2, $3)
+		) >= $4)`),
+			args: []interface{}{int64(7), "home", "work", int64(2)},
+		},
+		{
+			name:   "tagged without tags matches every task",
+			userID: 7,
+			filter: TaskFilter{TaggedWith(nil, false)},
+			want:   and("(user_id = $1)", "(TRUE)"),
+			args:   []interface{}{int64(7)},
+		},
+		{
+			name:       "title",
+			userID:     7,
+			filter:     TaskFilter{TitleMatches("Buy milk")},
+			want:       and("(user_id = $1)", "(to_tsvector('simple', title) @@ plainto_tsquery('simple', $2))"),
+			args:       []interface{}{int64(7), "Buy milk"},
+			sqliteWant: and("(user_id = $1)", "(id IN (SELECT rowid FROM task_search WHERE task_search MATCH $2))"),
+			sqliteArgs: []interface{}{int64(7), `title : "buy" AND title : "milk"`},
+		},
+		{
+			name:       "title without words matches nothing in sqlite",
+			userID:     7,
+			filter:     TaskFilter{TitleMatches("?!")},
+			want:       and("(user_id = $1)", "(to_tsvector('simple', title) @@ plainto_tsquery('simple', $2))"),
+			args:       []interface{}{int64(7), "?!"},
+			sqliteWant: and("(user_id = $1)", "(FALSE)"),
+			sqliteArgs: []interface{}{int64(7)},
+		},
+		{
+			name:   "every condition",
+			userID: 7,
+			filter: TaskFilter{InList(3), CompletedIs(false), Not(IDIn(4)), Not(PriorityIn(PriorityNone))},
+			want: and("(user_id = $1)", "(list_id = $2)", "(completed = $3)",
+				"(NOT COALESCE(id IN ($4), FALSE))", "(NOT COALESCE(priority IN ($5), FALSE))"),
+			args: []interface{}{int64(7), int64(3), false, int64(4), int64(PriorityNone)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &whereBuilder{}
+			if got := b.where(tt.userID, tt.filter); got != tt.want {
+				t.Errorf("where() = %q, want %q", got, tt.want)
+			}
+			if !reflect.DeepEqual(b.args, tt.args) {
+				t.Errorf("args = %#v, want %#v", b.args, tt.args)
+			}
+
+			sqliteWant := tt.want
+			if tt.sqliteWant != "" {
+				sqliteWant = tt.sqliteWant
+			}
+			sqliteWant = strings.ReplaceAll(sqliteWant, "$", "?")
+			sqliteArgs := tt.args
+			if tt.sqliteArgs != nil {
+				sqliteArgs = tt.sqliteArgs
+			}
+			b = &whereBuilder{sqlite: true}
+			if got := b.where(tt.userID, tt.filter); got != sqliteWant {
+				t.Errorf("sqlite where() = %q, want %q", got, sqliteWant)
+			}
+			if !reflect.DeepEqual(b.args, sqliteArgs) {
+				t.Errorf("sqlite args = %#v, want %#v", b.args, sqliteArgs)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+// File: todoApi/backend/internal/data/tokens.go
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"time"
+
+	"todo.michaelgomez.net/internal/validator"
+)
+
+// the scopes a token can be issued for
+const (
+	ScopeActivation     = "activation"
+	ScopeAuthentication = "authentication"
+	ScopePasswordReset  = "password-reset"
+)
+
+// token struct supports the information for a bearer token, only the hash is ever stored
+type Token struct {
+	Plaintext string    `json:"token"`
+	Hash      []byte    `json:"-"`
+	UserID    int64     `json:"-"`
+	Expiry    time.Time `json:"expiry"`
+	Scope     string    `json:"-"`
+}
+
+// generateToken() creates a random token for the user along with its SHA-256 hash
+func generateToken(userID int64, ttl time.Duration, scope string) (*Token, error) {
+	token := &Token{
+		UserID: userID,
+		Expiry: time.Now().Add(ttl),
+		Scope:  scope,
+	}
+
+	//16 random bytes encode to a 26 character base32 string
+	randomBytes := make([]byte, 16)
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	token.Plaintext = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes)
+	hash := sha256.Sum256([]byte(token.Plaintext))
+	token.Hash = hash[:]
+
+	return token, nil
+}
+
+func ValidateTokenPlaintext(v *validator.Validator, tokenPlaintext string) {
+	v.Check(tokenPlaintext != "", "token", "must be provided")
+	v.Check(len(tokenPlaintext) == 26, "token", "must be 26 bytes long")
+}
+
+type TokenModel struct {
+	DB      *sql.DB
+	Timeout time.Duration //the longest a single query may run
+}
+
+// New() generates a token for the user and stores its hash
+func (m TokenModel) New(ctx context.Context, userID int64, ttl time.Duration, scope string) (*Token, error) {
+	token, err := generateToken(userID, ttl, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.Insert(ctx, token)
+	return token, err
+}
+
+// Insert() stores a token
+func (m TokenModel) Insert(ctx context.Context, token *Token) error {
+	//creating the context
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	//Cleaning up to prevent memory leaks
+	defer cancel()
+
+	return insertToken(ctx, m.DB, token)
+}
+
+// insertToken() stores a token, q is the pool or the caller's transaction
+func insertToken(ctx context.Context, q queryer, token *Token) error {
+	query := `
+		INSERT INTO tokens (hash, user_id, expiry, scope)
+		VALUES ($1, $2, $3, $4)
+	`
+	args := []interface{}{token.Hash, token.UserID, token.Expiry, token.Scope}
+
+	_, err := q.ExecContext(ctx, query, args...)
+	return err
+}
+
+// DeleteAllForUser() removes every token of a scope that belongs to the user
+func (m TokenModel) DeleteAllForUser(ctx context.Context, scope string, userID int64) error {
+	query := `
+		DELETE FROM tokens
+		WHERE scope = $1 AND user_id = $2
+	`
+
+	//creating the context
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	//Cleaning up to prevent memory leaks
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, scope, userID)
+	return err
+}
@@ -0,0 +1,44 @@
+// File: todoApi/backend/internal/mailer/log.go
+package mailer
+
+import (
+	"io"
+	"sync"
+)
+
+// LogMailer writes rendered emails to an io.Writer instead of sending them
+// it is meant for local development and tests, point it at os.Stdout or an open file
+type LogMailer struct {
+	mu     *sync.Mutex
+	out    io.Writer
+	sender string
+}
+
+// NewLog() returns a mailer that writes every message to out
+func NewLog(out io.Writer, sender string) LogMailer {
+	return LogMailer{
+		mu:     &sync.Mutex{},
+		out:    out,
+		sender: sender,
+	}
+}
+
+// Send() renders the template and writes the full MIME message followed by a blank line
+func (m LogMailer) Send(recipient, templateFile string, data interface{}) error {
+	msg, err := Render(m.sender, recipient, templateFile, data)
+	if err != nil {
+		return err
+	}
+
+	body, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+
+	//messages can be sent from several goroutines at once so the writes are serialised
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err = m.out.Write(append(body, '\r', '\n'))
+	return err
+}
@@ -0,0 +1,119 @@
+// File: todoApi/backend/internal/mailer/smtp.go
+package mailer
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"time"
+)
+
+// SMTPMailer delivers emails through an SMTP server
+type SMTPMailer struct {
+	host     string
+	port     int
+	username string
+	password string
+	sender   string
+	timeout  time.Duration
+}
+
+// NewSMTP() returns a mailer that sends through the SMTP server at host:port
+// the username and password are only used when the server supports authentication
+func NewSMTP(host string, port int, username, password, sender string) SMTPMailer {
+	return SMTPMailer{
+		host:     host,
+		port:     port,
+		username: username,
+		password: password,
+		sender:   sender,
+		timeout:  5 * time.Second,
+	}
+}
+
+// Send() renders the template and delivers it, trying up to three times before giving up
+func (m SMTPMailer) Send(recipient, templateFile string, data interface{}) error {
+	msg, err := Render(m.sender, recipient, templateFile, data)
+	if err != nil {
+		return err
+	}
+
+	for i := 1; i <= 3; i++ {
+		err = m.deliver(msg)
+		if err == nil {
+			return nil
+		}
+		//waiting a little before the next attempt
+		time.Sleep(500 * time.Millisecond)
+	}
+	return err
+}
+
+// deliver() sends a single message over a new SMTP connection
+func (m SMTPMailer) deliver(msg *Message) error {
+	//the envelope needs the bare addresses without display names
+	from, err := mail.ParseAddress(msg.From)
+	if err != nil {
+		return err
+	}
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return err
+	}
+
+	body, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.DialTimeout("tcp", fmt.Sprintf("%s:%d", m.host, m.port), m.timeout)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(m.timeout))
+
+	c, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		err = c.StartTLS(&tls.Config{ServerName: m.host})
+		if err != nil {
+			return err
+		}
+	}
+	if ok, _ := c.Extension("AUTH"); ok && m.username != "" {
+		err = c.Auth(smtp.PlainAuth("", m.username, m.password, m.host))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = c.Mail(from.Address)
+	if err != nil {
+		return err
+	}
+	err = c.Rcpt(to.Address)
+	if err != nil {
+		return err
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(body)
+	if err != nil {
+		return err
+	}
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+	return c.Quit()
+}
@@ -0,0 +1,524 @@
+// File: todoApi/backend/internal/migrate/migrate.go
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// lockKey identifies the Postgres advisory lock held while migrating so only one instance migrates at a time
+const lockKey int64 = 7_301_946_220_118
+
+var (
+	ErrDirty            = errors.New("the database is dirty, a migration failed part way through and has to be fixed by hand")
+	ErrChecksumMismatch = errors.New("an applied migration has been changed since it was applied")
+	ErrUnknownVersion   = errors.New("unknown migration version")
+)
+
+// fileRX matches migration files such as 000001_create_tasks_table.up.sql
+var fileRX = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is a numbered pair of up and down SQL scripts
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string //SHA-256 of the up script, used to spot migrations edited after they were applied
+}
+
+// Status describes one migration as seen by the database
+type Status struct {
+	Version          int64
+	Name             string
+	Applied          bool
+	AppliedAt        time.Time
+	Dirty            bool
+	ChecksumMismatch bool
+	Missing          bool //applied to the database but not known to this binary
+}
+
+// appliedMigration is a row of the schema_migrations table
+type appliedMigration struct {
+	version   int64
+	name      string
+	checksum  string
+	dirty     bool
+	appliedAt time.Time
+}
+
+// Migrator applies the migrations to a database
+type Migrator struct {
+	db         *sql.DB
+	logger     *slog.Logger
+	migrations []Migration //sorted by version
+	sqlite     bool
+}
+
+// New() loads every migration in fsys, each version needs both an up and a down script
+func New(db *sql.DB, fsys fs.FS, logger *slog.Logger) (*Migrator, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		match := fileRX.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", entry.Name(), err)
+		}
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(content)
+			sum := sha256.Sum256(content)
+			m.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrator := &Migrator{db: db, logger: logger}
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down script", m.Version, m.Name)
+		}
+		migrator.migrations = append(migrator.migrations, *m)
+	}
+	sort.Slice(migrator.migrations, func(i, j int) bool {
+		return migrator.migrations[i].Version < migrator.migrations[j].Version
+	})
+
+	if migrator.logger == nil {
+		migrator.logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	return migrator, nil
+}
+
+// NewSQLite() is New() for a SQLite database, which has neither advisory locks nor a golang-migrate table to convert
+func NewSQLite(db *sql.DB, fsys fs.FS, logger *slog.Logger) (*Migrator, error) {
+	migrator, err := New(db, fsys, logger)
+	if err != nil {
+		return nil, err
+	}
+	migrator.sqlite = true
+	return migrator, nil
+}
+
+// Latest() returns the highest version known to the binary, 0 when there are none
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Up() applies every migration that has not been applied yet
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.Goto(ctx, m.Latest())
+}
+
+// Down() reverts the most recently applied migration
+func (m *Migrator) Down(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.check(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			if _, ok := applied[m.migrations[i].Version]; ok {
+				return m.revert(ctx, conn, m.migrations[i])
+			}
+		}
+		m.logger.Info("no migrations to revert")
+		return nil
+	})
+}
+
+// Goto() applies or reverts migrations until version is the latest one applied, 0 reverts everything
+func (m *Migrator) Goto(ctx context.Context, version int64) error {
+	if version != 0 && m.find(version) == nil {
+		return fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.check(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		//reverting from the newest down to version
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.Version]; ok && mig.Version > version {
+				if err := m.revert(ctx, conn, mig); err != nil {
+					return err
+				}
+			}
+		}
+
+		//then applying the missing ones up to version
+		changed := false
+		for _, mig := range m.migrations {
+			if _, ok := applied[mig.Version]; !ok && mig.Version <= version {
+				if err := m.apply(ctx, conn, mig); err != nil {
+					return err
+				}
+				changed = true
+			}
+		}
+		if !changed {
+			m.logger.Info("no migrations to apply", "version", version)
+		}
+		return nil
+	})
+}
+
+// Force() records version and everything before it as cleanly applied without running any SQL
+// it is how a dirty database is marked as fixed once it has been repaired by hand
+func (m *Migrator) Force(ctx context.Context, version int64) error {
+	if version != 0 && m.find(version) == nil {
+		return fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM schema_migrations`)
+		if err != nil {
+			return err
+		}
+		for _, mig := range m.migrations {
+			if mig.Version > version {
+				break
+			}
+			err = insertApplied(ctx, tx, mig, false)
+			if err != nil {
+				return err
+			}
+		}
+		return tx.Commit()
+	})
+}
+
+// Status() reports every known migration along with those applied by a newer binary
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range m.migrations {
+			s := Status{Version: mig.Version, Name: mig.Name}
+			if a, ok := applied[mig.Version]; ok {
+				s.Applied = true
+				s.AppliedAt = a.appliedAt
+				s.Dirty = a.dirty
+				s.ChecksumMismatch = a.checksum != mig.Checksum
+				delete(applied, mig.Version)
+			}
+			statuses = append(statuses, s)
+		}
+		for _, a := range applied {
+			statuses = append(statuses, Status{Version: a.version, Name: a.name, Applied: true, AppliedAt: a.appliedAt, Dirty: a.dirty, Missing: true})
+		}
+		return nil
+	})
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+	return statuses, err
+}
+
+// find() returns the migration with the version or nil
+func (m *Migrator) find(version int64) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i]
+		}
+	}
+	return nil
+}
+
+// check() returns the applied migrations, refusing to go on when the database is dirty or has drifted from the binary
+func (m *Migrator) check(ctx context.Context, conn *sql.Conn) (map[int64]appliedMigration, error) {
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, a := range applied {
+		if a.dirty {
+			return nil, fmt.Errorf("%w (version %d)", ErrDirty, a.version)
+		}
+		mig := m.find(a.version)
+		if mig == nil {
+			return nil, fmt.Errorf("%w: %d is applied but this binary does not know it", ErrUnknownVersion, a.version)
+		}
+		if mig.Checksum != a.checksum {
+			return nil, fmt.Errorf("%w: %d_%s", ErrChecksumMismatch, mig.Version, mig.Name)
+		}
+	}
+	return applied, nil
+}
+
+// apply() runs an up script, the version is marked dirty until the script has committed
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, mig Migration) error {
+	m.logger.Info("applying migration", "version", mig.Version, "name", mig.Name)
+	start := time.Now()
+
+	err := insertApplied(ctx, conn, mig, true)
+	if err != nil {
+		return err
+	}
+
+	err = m.run(ctx, conn, mig.Up, `UPDATE schema_migrations SET dirty = false WHERE version = $1`, mig.Version)
+	if err != nil {
+		//the script ran in a transaction that has been rolled back so the schema is still clean
+		if _, cleanupErr := conn.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = $1`, mig.Version); cleanupErr != nil {
+			m.logger.Error(cleanupErr.Error(), "version", mig.Version)
+		}
+		return fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, err)
+	}
+
+	m.logger.Info("applied migration", "version", mig.Version, "name", mig.Name, "duration", time.Since(start))
+	return nil
+}
+
+// revert() runs a down script, the version is marked dirty until the script has committed
+func (m *Migrator) revert(ctx context.Context, conn *sql.Conn, mig Migration) error {
+	m.logger.Info("reverting migration", "version", mig.Version, "name", mig.Name)
+	start := time.Now()
+
+	_, err := conn.ExecContext(ctx, `UPDATE schema_migrations SET dirty = true WHERE version = $1`, mig.Version)
+	if err != nil {
+		return err
+	}
+
+	err = m.run(ctx, conn, mig.Down, `DELETE FROM schema_migrations WHERE version = $1`, mig.Version)
+	if err != nil {
+		if _, cleanupErr := conn.ExecContext(ctx, `UPDATE schema_migrations SET dirty = false WHERE version = $1`, mig.Version); cleanupErr != nil {
+			m.logger.Error(cleanupErr.Error(), "version", mig.Version)
+		}
+		return fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, err)
+	}
+
+	m.logger.Info("reverted migration", "version", mig.Version, "name", mig.Name, "duration", time.Since(start))
+	return nil
+}
+
+// run() executes a script and the bookkeeping statement in a single transaction
+func (m *Migrator) run(ctx context.Context, conn *sql.Conn, script, record string, version int64) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, script)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, record, version)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// execer is satisfied by *sql.Conn and *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// insertApplied() records a migration in schema_migrations
+func insertApplied(ctx context.Context, db execer, mig Migration, dirty bool) error {
+	query := `
+		INSERT INTO schema_migrations (version, name, checksum, dirty)
+		VALUES ($1, $2, $3, $4)
+	`
+	_, err := db.ExecContext(ctx, query, mig.Version, mig.Name, mig.Checksum, dirty)
+	return err
+}
+
+// applied() returns the rows of schema_migrations keyed by version
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) (map[int64]appliedMigration, error) {
+	query := `
+		SELECT version, name, checksum, dirty, applied_at
+		FROM schema_migrations
+	`
+
+	rows, err := conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]appliedMigration)
+	for rows.Next() {
+		var a appliedMigration
+		err := rows.Scan(&a.version, &a.name, &a.checksum, &a.dirty, &a.appliedAt)
+		if err != nil {
+			return nil, err
+		}
+		applied[a.version] = a
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+// withLock() runs fn on a single connection holding the advisory lock
+// advisory locks belong to a session so every statement has to go through the same connection
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	//a SQLite database belongs to a single process and its write transactions are already serialised
+	if m.sqlite {
+		err = m.ensureSQLiteTable(ctx, conn)
+		if err != nil {
+			return err
+		}
+		return fn(conn)
+	}
+
+	var locked bool
+	err = conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, lockKey).Scan(&locked)
+	if err != nil {
+		return err
+	}
+	if !locked {
+		m.logger.Info("waiting for another instance to finish migrating")
+		_, err = conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockKey)
+		if err != nil {
+			return err
+		}
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockKey)
+
+	err = m.ensureTable(ctx, conn)
+	if err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+// ensureTable() creates schema_migrations, converting the single row table left by golang-migrate if there is one
+func (m *Migrator) ensureTable(ctx context.Context, conn *sql.Conn) error {
+	var legacy bool
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM information_schema.tables
+			WHERE table_schema = current_schema() AND table_name = 'schema_migrations'
+		) AND NOT EXISTS (
+			SELECT 1 FROM information_schema.columns
+			WHERE table_schema = current_schema() AND table_name = 'schema_migrations' AND column_name = 'checksum'
+		)
+	`
+	err := conn.QueryRowContext(ctx, query).Scan(&legacy)
+	if err != nil {
+		return err
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var legacyVersion int64
+	var legacyDirty bool
+	if legacy {
+		err = tx.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&legacyVersion, &legacyDirty)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `DROP TABLE schema_migrations`)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version bigint PRIMARY KEY,
+			name text NOT NULL,
+			checksum text NOT NULL,
+			dirty boolean NOT NULL DEFAULT false,
+			applied_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	//golang-migrate only kept the latest version, everything up to it had been applied
+	if legacy {
+		m.logger.Info("converting golang-migrate schema_migrations table", "version", legacyVersion, "dirty", legacyDirty)
+		for _, mig := range m.migrations {
+			if mig.Version > legacyVersion {
+				break
+			}
+			err = insertApplied(ctx, tx, mig, legacyDirty && mig.Version == legacyVersion)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// ensureSQLiteTable() creates schema_migrations in a SQLite database
+func (m *Migrator) ensureSQLiteTable(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version integer PRIMARY KEY,
+			name text NOT NULL,
+			checksum text NOT NULL,
+			dirty boolean NOT NULL DEFAULT false,
+			applied_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	return err
+}
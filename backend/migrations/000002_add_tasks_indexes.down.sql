@@ -1,3 +1,3 @@
---File: todoApi/backend/migrations/000001_add_tasks_indexes.down.sql
+--File: todoApi/backend/migrations/000002_add_tasks_indexes.down.sql
 drop index if exists tasks_title_idx;
 drop index if exists tasks_description_idx;
\ No newline at end of file
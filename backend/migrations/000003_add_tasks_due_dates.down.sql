@@ -0,0 +1,4 @@
+--File: todoApi/backend/migrations/000003_add_tasks_due_dates.down.sql
+drop index if exists tasks_due_at_idx;
+alter table task_list drop column if exists remind_at;
+alter table task_list drop column if exists due_at;
\ No newline at end of file
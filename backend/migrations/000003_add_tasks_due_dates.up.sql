@@ -0,0 +1,4 @@
+--File: todoApi/backend/migrations/000003_add_tasks_due_dates.up.sql
+alter table task_list add column if not exists due_at timestamp(0) with time zone;
+alter table task_list add column if not exists remind_at timestamp(0) with time zone;
+create index if not exists tasks_due_at_idx on task_list(due_at);
\ No newline at end of file
@@ -0,0 +1,4 @@
+--File: todoApi/backend/migrations/000004_add_tasks_priority.down.sql
+drop index if exists tasks_priority_idx;
+alter table task_list drop constraint if exists tasks_priority_check;
+alter table task_list drop column if exists priority;
\ No newline at end of file
@@ -0,0 +1,4 @@
+--File: todoApi/backend/migrations/000004_add_tasks_priority.up.sql
+alter table task_list add column if not exists priority smallint not null default 0;
+alter table task_list add constraint tasks_priority_check check (priority between 0 and 4);
+create index if not exists tasks_priority_idx on task_list(priority);
\ No newline at end of file
@@ -0,0 +1,3 @@
+--File: todoApi/backend/migrations/000005_create_tags_tables.down.sql
+drop table if exists task_tags;
+drop table if exists tags;
\ No newline at end of file
@@ -0,0 +1,15 @@
+--File: todoApi/backend/migrations/000005_create_tags_tables.up.sql
+create table if not exists tags(
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone not null default now(),
+    name text not null unique,
+    version int not null default 1
+);
+
+create table if not exists task_tags(
+    task_id bigint not null references task_list on delete cascade,
+    tag_id bigint not null references tags on delete cascade,
+    PRIMARY KEY (task_id, tag_id)
+);
+
+create index if not exists task_tags_tag_id_idx on task_tags(tag_id);
\ No newline at end of file
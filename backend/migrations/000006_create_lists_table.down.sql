@@ -0,0 +1,4 @@
+--File: todoApi/backend/migrations/000006_create_lists_table.down.sql
+drop index if exists tasks_list_id_idx;
+alter table task_list drop column if exists list_id;
+drop table if exists lists;
\ No newline at end of file
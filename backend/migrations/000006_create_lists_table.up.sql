@@ -0,0 +1,11 @@
+--File: todoApi/backend/migrations/000006_create_lists_table.up.sql
+create table if not exists lists(
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone not null default now(),
+    name text not null,
+    description text not null default '',
+    version int not null default 1
+);
+
+alter table task_list add column if not exists list_id bigint references lists;
+create index if not exists tasks_list_id_idx on task_list(list_id);
\ No newline at end of file
@@ -0,0 +1,3 @@
+--File: todoApi/backend/migrations/000007_add_tasks_parent.down.sql
+drop index if exists tasks_parent_id_idx;
+alter table task_list drop column if exists parent_id;
\ No newline at end of file
@@ -0,0 +1,3 @@
+--File: todoApi/backend/migrations/000007_add_tasks_parent.up.sql
+alter table task_list add column if not exists parent_id bigint references task_list on delete cascade;
+create index if not exists tasks_parent_id_idx on task_list(parent_id);
\ No newline at end of file
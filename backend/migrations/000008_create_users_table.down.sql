@@ -0,0 +1,4 @@
+--File: todoApi/backend/migrations/000008_create_users_table.down.sql
+drop index if exists tasks_user_id_idx;
+alter table task_list drop column if exists user_id;
+drop table if exists users;
\ No newline at end of file
@@ -0,0 +1,15 @@
+--File: todoApi/backend/migrations/000008_create_users_table.up.sql
+create extension if not exists citext;
+
+create table if not exists users(
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone not null default now(),
+    name text not null,
+    email citext unique not null,
+    password_hash bytea not null,
+    activated bool not null,
+    version int not null default 1
+);
+
+alter table task_list add column if not exists user_id bigint references users on delete cascade;
+create index if not exists tasks_user_id_idx on task_list(user_id);
\ No newline at end of file
@@ -0,0 +1,2 @@
+--File: todoApi/backend/migrations/000009_create_tokens_table.down.sql
+drop table if exists tokens;
\ No newline at end of file
@@ -0,0 +1,7 @@
+--File: todoApi/backend/migrations/000009_create_tokens_table.up.sql
+create table if not exists tokens(
+    hash bytea PRIMARY KEY,
+    user_id bigint not null references users on delete cascade,
+    expiry timestamp(0) with time zone not null,
+    scope text not null
+);
\ No newline at end of file
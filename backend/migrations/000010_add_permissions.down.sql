@@ -0,0 +1,3 @@
+--File: todoApi/backend/migrations/000010_add_permissions.down.sql
+drop table if exists users_permissions;
+drop table if exists permissions;
\ No newline at end of file
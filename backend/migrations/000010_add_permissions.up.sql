@@ -0,0 +1,21 @@
+--File: todoApi/backend/migrations/000010_add_permissions.up.sql
+create table if not exists permissions(
+    id bigserial PRIMARY KEY,
+    code text not null unique
+);
+
+create table if not exists users_permissions(
+    user_id bigint not null references users on delete cascade,
+    permission_id bigint not null references permissions on delete cascade,
+    PRIMARY KEY (user_id, permission_id)
+);
+
+insert into permissions (code)
+values ('todo:read'), ('todo:write'), ('todo:admin')
+on conflict (code) do nothing;
+
+--existing accounts keep the access they had before permissions were introduced
+insert into users_permissions
+select users.id, permissions.id from users cross join permissions
+where permissions.code in ('todo:read', 'todo:write')
+on conflict do nothing;
\ No newline at end of file
@@ -0,0 +1,2 @@
+--File: todoApi/backend/migrations/000011_activate_existing_users.down.sql
+--the accounts activated by the up migration cannot be told apart from the others so nothing is undone
\ No newline at end of file
@@ -0,0 +1,3 @@
+--File: todoApi/backend/migrations/000011_activate_existing_users.up.sql
+--accounts registered before activation emails existed had no way to activate themselves
+update users set activated = true where activated = false;
\ No newline at end of file
@@ -0,0 +1,2 @@
+--File: todoApi/backend/migrations/000012_add_tasks_search_index.down.sql
+drop index if exists tasks_search_idx;
\ No newline at end of file
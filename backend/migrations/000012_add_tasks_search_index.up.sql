@@ -0,0 +1,3 @@
+--File: todoApi/backend/migrations/000012_add_tasks_search_index.up.sql
+--the weighted title and description document searched by GET /v1/todo/search with the simple configuration
+create index if not exists tasks_search_idx on task_list using gin((setweight(to_tsvector('simple', title), 'A') || setweight(to_tsvector('simple', description), 'B')));
\ No newline at end of file
@@ -0,0 +1,15 @@
+--File: todoApi/backend/migrations/000013_add_lists_tags_owner.down.sql
+--the copies of a tag are merged back into the oldest one
+update task_tags set tag_id = kept.id
+from tags, (select min(id) as id, name from tags group by name) kept
+where tags.id = task_tags.tag_id
+and kept.name = tags.name;
+
+delete from tags where id not in (select min(id) from tags group by name);
+
+drop index if exists tags_user_id_name_idx;
+alter table tags drop column if exists user_id;
+alter table tags add constraint tags_name_key unique (name);
+
+drop index if exists lists_user_id_idx;
+alter table lists drop column if exists user_id;
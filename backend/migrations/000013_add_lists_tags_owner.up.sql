@@ -0,0 +1,35 @@
+--File: todoApi/backend/migrations/000013_add_lists_tags_owner.up.sql
+alter table lists add column if not exists user_id bigint references users on delete cascade;
+create index if not exists lists_user_id_idx on lists(user_id);
+
+--a list goes to the user owning every task in it, lists shared by several users are left without an owner for todo:admin
+update lists set user_id = owners.user_id
+from (
+    select list_id, min(user_id) as user_id from task_list
+    where list_id is not null
+    group by list_id
+    having count(distinct user_id) = 1 and count(user_id) = count(*)
+) owners
+where owners.list_id = lists.id;
+
+alter table tags add column if not exists user_id bigint references users on delete cascade;
+alter table tags drop constraint if exists tags_name_key;
+--the tags without an owner belong to the tasks without one, NULL is folded into 0 so they stay unique too
+create unique index if not exists tags_user_id_name_idx on tags(coalesce(user_id, 0), name);
+
+--every user gets their own copy of the shared tags their tasks carry
+insert into tags (user_id, name)
+select distinct task_list.user_id, tags.name
+from tags
+join task_tags on task_tags.tag_id = tags.id
+join task_list on task_list.id = task_tags.task_id
+where task_list.user_id is not null
+on conflict do nothing;
+
+update task_tags set tag_id = owned.id
+from task_list, tags shared, tags owned
+where task_list.id = task_tags.task_id
+and shared.id = task_tags.tag_id
+and shared.user_id is null
+and owned.user_id = task_list.user_id
+and owned.name = shared.name;
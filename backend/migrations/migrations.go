@@ -0,0 +1,24 @@
+// File: todoApi/backend/migrations/migrations.go
+package migrations
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// FS holds the SQL migrations so the binary can apply them without the source tree
+//
+//go:embed *.sql
+var FS embed.FS
+
+//go:embed sqlite/*.sql
+var sqliteFS embed.FS
+
+// SQLite() returns the migrations for the SQLite storage backend, they mirror the Postgres schema
+func SQLite() fs.FS {
+	sub, err := fs.Sub(sqliteFS, "sqlite")
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
@@ -0,0 +1,9 @@
+--File: todoApi/backend/migrations/sqlite/000001_create_schema.down.sql
+drop table if exists users_permissions;
+drop table if exists permissions;
+drop table if exists tokens;
+drop table if exists task_tags;
+drop table if exists tags;
+drop table if exists task_list;
+drop table if exists lists;
+drop table if exists users;
\ No newline at end of file
@@ -0,0 +1,77 @@
+--File: todoApi/backend/migrations/sqlite/000001_create_schema.up.sql
+--SQLite has no timestamptz, times are stored as UTC text in a fixed width format so they sort and compare as strings
+create table if not exists users(
+    id integer PRIMARY KEY AUTOINCREMENT,
+    created_at timestamp not null,
+    name text not null,
+    email text unique not null collate nocase,
+    password_hash blob not null,
+    activated boolean not null,
+    version integer not null default 1
+);
+
+create table if not exists lists(
+    id integer PRIMARY KEY AUTOINCREMENT,
+    created_at timestamp not null,
+    name text not null,
+    description text not null default '',
+    version integer not null default 1
+);
+
+create table if not exists task_list(
+    id integer PRIMARY KEY AUTOINCREMENT,
+    created_at timestamp not null,
+    title text not null,
+    description text not null,
+    completed boolean not null,
+    version integer not null default 1,
+    due_at timestamp,
+    remind_at timestamp,
+    priority integer not null default 0 check (priority between 0 and 4),
+    list_id integer references lists,
+    parent_id integer references task_list on delete cascade,
+    user_id integer references users on delete cascade
+);
+
+create index if not exists tasks_due_at_idx on task_list(due_at);
+create index if not exists tasks_priority_idx on task_list(priority);
+create index if not exists tasks_list_id_idx on task_list(list_id);
+create index if not exists tasks_parent_id_idx on task_list(parent_id);
+create index if not exists tasks_user_id_idx on task_list(user_id);
+
+create table if not exists tags(
+    id integer PRIMARY KEY AUTOINCREMENT,
+    created_at timestamp not null,
+    name text not null unique,
+    version integer not null default 1
+);
+
+create table if not exists task_tags(
+    task_id integer not null references task_list on delete cascade,
+    tag_id integer not null references tags on delete cascade,
+    PRIMARY KEY (task_id, tag_id)
+);
+
+create index if not exists task_tags_tag_id_idx on task_tags(tag_id);
+
+create table if not exists tokens(
+    hash blob PRIMARY KEY,
+    user_id integer not null references users on delete cascade,
+    expiry timestamp not null,
+    scope text not null
+);
+
+create table if not exists permissions(
+    id integer PRIMARY KEY AUTOINCREMENT,
+    code text not null unique
+);
+
+create table if not exists users_permissions(
+    user_id integer not null references users on delete cascade,
+    permission_id integer not null references permissions on delete cascade,
+    PRIMARY KEY (user_id, permission_id)
+);
+
+insert into permissions (code)
+values ('todo:read'), ('todo:write'), ('todo:admin')
+on conflict (code) do nothing;
\ No newline at end of file
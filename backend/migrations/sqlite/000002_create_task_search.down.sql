@@ -0,0 +1,5 @@
+--File: todoApi/backend/migrations/sqlite/000002_create_task_search.down.sql
+drop trigger if exists task_search_update;
+drop trigger if exists task_search_delete;
+drop trigger if exists task_search_insert;
+drop table if exists task_search;
\ No newline at end of file
@@ -0,0 +1,25 @@
+--File: todoApi/backend/migrations/sqlite/000002_create_task_search.up.sql
+--stands in for the to_tsvector('simple', ...) gin indexes, unicode61 splits words the same way the simple config does
+create virtual table if not exists task_search using fts5(
+    title,
+    description,
+    content = 'task_list',
+    content_rowid = 'id',
+    tokenize = 'unicode61 remove_diacritics 0'
+);
+
+insert into task_search (rowid, title, description)
+select id, title, description from task_list;
+
+create trigger if not exists task_search_insert after insert on task_list begin
+    insert into task_search (rowid, title, description) values (new.id, new.title, new.description);
+end;
+
+create trigger if not exists task_search_delete after delete on task_list begin
+    insert into task_search (task_search, rowid, title, description) values ('delete', old.id, old.title, old.description);
+end;
+
+create trigger if not exists task_search_update after update of title, description on task_list begin
+    insert into task_search (task_search, rowid, title, description) values ('delete', old.id, old.title, old.description);
+    insert into task_search (rowid, title, description) values (new.id, new.title, new.description);
+end;
\ No newline at end of file
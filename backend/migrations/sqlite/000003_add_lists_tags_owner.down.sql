@@ -0,0 +1,33 @@
+--File: todoApi/backend/migrations/sqlite/000003_add_lists_tags_owner.down.sql
+--the copies of a tag are merged back into the oldest one
+create table tags_shared(
+    id integer PRIMARY KEY AUTOINCREMENT,
+    created_at timestamp not null,
+    name text not null unique,
+    version integer not null default 1
+);
+
+create table task_tags_shared(
+    task_id integer not null references task_list on delete cascade,
+    tag_id integer not null references tags_shared on delete cascade,
+    PRIMARY KEY (task_id, tag_id)
+);
+
+insert into tags_shared (id, created_at, name, version)
+select id, created_at, name, version from tags
+where id in (select min(id) from tags group by name);
+
+insert or ignore into task_tags_shared (task_id, tag_id)
+select task_tags.task_id, tags_shared.id
+from task_tags
+join tags on tags.id = task_tags.tag_id
+join tags_shared on tags_shared.name = tags.name;
+
+drop table task_tags;
+drop table tags;
+alter table tags_shared rename to tags;
+alter table task_tags_shared rename to task_tags;
+create index if not exists task_tags_tag_id_idx on task_tags(tag_id);
+
+drop index if exists lists_user_id_idx;
+alter table lists drop column user_id;
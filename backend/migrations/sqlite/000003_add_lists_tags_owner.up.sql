@@ -0,0 +1,53 @@
+--File: todoApi/backend/migrations/sqlite/000003_add_lists_tags_owner.up.sql
+alter table lists add column user_id integer references users on delete cascade;
+create index if not exists lists_user_id_idx on lists(user_id);
+
+--a list goes to the user owning every task in it, lists shared by several users are left without an owner for todo:admin
+update lists set user_id = (
+    select min(user_id) from task_list
+    where task_list.list_id = lists.id
+    having count(distinct user_id) = 1 and count(user_id) = count(*)
+);
+
+--SQLite cannot drop the unique constraint on name, so tags is rebuilt
+--task_tags is rebuilt with it because dropping tags would cascade into it
+create table tags_owned(
+    id integer PRIMARY KEY AUTOINCREMENT,
+    created_at timestamp not null,
+    name text not null,
+    version integer not null default 1,
+    user_id integer references users on delete cascade
+);
+
+create table task_tags_owned(
+    task_id integer not null references task_list on delete cascade,
+    tag_id integer not null references tags_owned on delete cascade,
+    PRIMARY KEY (task_id, tag_id)
+);
+
+insert into tags_owned (id, created_at, name, version)
+select id, created_at, name, version from tags;
+
+--every user gets their own copy of the shared tags their tasks carry
+insert into tags_owned (created_at, name, user_id)
+select distinct tags.created_at, tags.name, task_list.user_id
+from tags
+join task_tags on task_tags.tag_id = tags.id
+join task_list on task_list.id = task_tags.task_id
+where task_list.user_id is not null;
+
+insert into task_tags_owned (task_id, tag_id)
+select task_tags.task_id, coalesce(owned.id, task_tags.tag_id)
+from task_tags
+join task_list on task_list.id = task_tags.task_id
+join tags on tags.id = task_tags.tag_id
+left join tags_owned owned on owned.user_id = task_list.user_id and owned.name = tags.name;
+
+drop table task_tags;
+drop table tags;
+alter table tags_owned rename to tags;
+alter table task_tags_owned rename to task_tags;
+
+--the tags without an owner belong to the tasks without one, NULL is folded into 0 so they stay unique too
+create unique index if not exists tags_user_id_name_idx on tags(coalesce(user_id, 0), name);
+create index if not exists task_tags_tag_id_idx on task_tags(tag_id);